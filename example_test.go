@@ -3,21 +3,29 @@ package salt_test
 import (
 	"github.com/aki237/salt"
 	"fmt"
+	"strconv"
 )
 
 func ExampleAddRoute(){
-	salt.AddRoute("/<all:user>/<str:post>/<int:commentid>$","commentid",showcomment)
+	err := salt.AddRoute("/<all:user>/<str:post>/<int:commentid>$","commentid",showcomment)
+	if err != nil {
+		//Do the error handling.
+		fmt.Println(err)
+	}
 	salt.AddRoute("/<all:user>/<str:post>$","post",showpost)
+	//The name of a route can only be used once.
+	err = salt.AddRoute("/<all:user>/comments/<int:commentid>$","commentid",showcomment)
+	fmt.Println(err)
 	// Output:
-	// pattern =  /(?P<user>[[:alnum:]]+)/(?P<post>[[:alpha:]]+)/(?P<commentid>[[:digit:]]+)$
-	// pattern =  /(?P<user>[[:alnum:]]+)/(?P<post>[[:alpha:]]+)
+	// The Name for this route is already used
 }
 
 func ExampleModifyRoute(){
 	//Here only the name of the route and the patterns are changed and not the handler function, which can be done too.
-	salt.ModifyRoute("post", "showpost", "/<all:user>/<str:post>/show$", showpost)
+	err := salt.ModifyRoute("post", "showpost", "/<all:user>/<str:post>/show$", showpost)
+	fmt.Println(err)
 	// Output:
-	// pattern =  /(?P<user>[[:alnum:]]+)/(?P<post>[[:alpha:]]+)/show
+	// <nil>
 }
 
 //
@@ -26,31 +34,31 @@ func ExampleValidate() {
 	if err != nil {
 		//Do the error handling.
 		fmt.Println(err)
+		return
 	}
+	fmt.Println(exp.String())
+	fmt.Println(exp.MatchString("/api/42"), exp.MatchString("/api/aki"))
 	// Output:
-	// pattern =  /api/(?P<userid>[[:alnum:]]+)
+	// /api/(?P<userid>[[:digit:]]+)
+	// true false
 }
 
-func ExampleAddNewRouteObject(){
+func ExampleRoute_AddNewRouteObject(){
 	exp,err := salt.Validate("/api/<int:userid>")
 	if err != nil {
 		//Do the error handling.
 		fmt.Println(err)
+		return
 	}
-	newroute := salt.Route{exp,"/api/<int:userid>",handler,"userdetails"}
-	if newroute.AddNewRouteObject() != nil {
-		//Do the error handling.
-		fmt.Println(err)
-	}
+	newroute := salt.Route{RegexpPattern: exp, Pattern: "/api/<int:userid>", Handler: handler, Name: "userdetails", Methods: []string{"GET"}}
+	fmt.Println(newroute.AddNewRouteObject())
+	fmt.Println(newroute.AddNewRouteObject())
 	// Output:
-	// If the route is valid :
-	// <nil>
 	// <nil>
-	// Else :
-	// Error : .....
-	// Error : .....
+	// There already exist a route with the same name.
 }
 
+//Configure needs the app.json of the app and its database, so this example is not run.
 func ExampleConfigure(){
 	//This step is to configure a salt app
 	err := salt.Configure("app.json")
@@ -58,8 +66,47 @@ func ExampleConfigure(){
 		//Do the error handling
 		fmt.Println(err)
 	}
+}
+
+func ExampleAddRouteMethods(){
+	//GET requests to /posts list the posts, POST requests create one. Any other method gets a 405 response.
+	fmt.Println(salt.AddRouteMethods([]string{"GET"}, "^/posts$", "listposts", listposts))
+	fmt.Println(salt.AddRouteMethods([]string{"POST"}, "^/posts$", "createpost", createpost))
+	// Output:
+	// <nil>
+	// <nil>
+}
+
+func ExampleURLFor(){
+	salt.AddRoute("^/<all:username>/posts/<int:postno>$","userpost",getpost)
+	path, err := salt.URLFor("userpost", map[string]interface{}{"username": "aki237", "postno": 898})
+	if err != nil {
+		//Do the error handling.
+		fmt.Println(err)
+	}
+	fmt.Println(path)
+	// Output:
+	// /aki237/posts/898
+}
+
+func ExampleRegisterParamType(){
+	//<year:year> only matches 4 digit years and URLParameters["year"] is an int.
+	salt.RegisterParamType("year", "[[:digit:]]{4}", func(s string) (interface{}, error) { return strconv.Atoi(s) })
+	salt.AddRoute("^/archive/<year:year>$", "archive", archive)
+	path, _ := salt.URLFor("archive", map[string]interface{}{"year": 2016})
+	fmt.Println(path)
+	_, err := salt.URLFor("archive", map[string]interface{}{"year": 16})
+	fmt.Println(err)
 	// Output:
-	// [Static File Directories detected :  [static/]]
-	// [pattern =  ^/static/(?P<staticfile>.+)]
-	// {true {8080 127.0.0.1} {^/static/ [static/]} {${Username} ${Password} ${Database}}}
+	// /archive/2016
+	// The value 16 is not valid for the url variable <year:year>
 }
+
+//The handlers used by the examples.
+func showcomment(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func showpost(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func handler(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func listposts(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func createpost(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func getpost(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
+func archive(w salt.ResponseBuffer, r *salt.RequestBuffer) {}
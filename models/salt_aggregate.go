@@ -0,0 +1,180 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+//aggregate is an aggregate function of a column, like SUM(Total), selected under its alias.
+type aggregate struct {
+	function string
+	column   string
+	alias    string
+}
+
+//The aggregates Select, Having and the Query methods accept : COUNT(*), COUNT(column), SUM(column), AVG(column),
+//MIN(column) and MAX(column), optionally followed by AS alias.
+var aggregatePattern = regexp.MustCompile(`(?i)^(COUNT|SUM|AVG|MIN|MAX)\(\s*(\*|[A-Za-z_][A-Za-z0-9_]*)\s*\)(?:\s+AS\s+([A-Za-z_][A-Za-z0-9_]*))?$`)
+
+//parseAggregate parses an aggregate expression. The alias is the expression itself when it has none.
+func parseAggregate(expression string) (aggregate, bool) {
+	expression = strings.TrimSpace(expression)
+	match := aggregatePattern.FindStringSubmatch(expression)
+	if match == nil {
+		return aggregate{}, false
+	}
+	parsed := aggregate{function : strings.ToUpper(match[1]), column : match[2], alias : match[3]}
+	if parsed.alias == "" {
+		parsed.alias = expression
+	}
+	return parsed, true
+}
+
+//sql returns the SQL of the aggregate function.
+func (a aggregate) sql() (string) {
+	if a.column == "*" {
+		return a.function + "(*)"
+	}
+	return a.function + "(" + quote(a.column) + ")"
+}
+
+//selectExpression returns the SQL of a column or an aggregate selected by a query.
+func selectExpression(column string) (string) {
+	if a, ok := parseAggregate(column); ok {
+		return a.sql() + " AS " + quote(a.alias)
+	}
+	return quote(column)
+}
+
+//aggregateField returns the field the values of the aggregate are read as : COUNT as Integer, SUM as BigInteger for
+//integer columns, AVG as Float (SUM and AVG of Decimal columns are Decimal) and MIN and MAX as the column.
+func (q *Query) aggregateField(a aggregate) (Field, error) {
+	if a.column == "*" {
+		if a.function != "COUNT" {
+			return Field{}, errors.New("Error : Only COUNT can be applied to *")
+		}
+		return Field{Type : Integer}, nil
+	}
+	field, ok := q.model.field(a.column)
+	if !ok {
+		return Field{}, errors.New("Error : No such field " + a.column + " in the model or it's owners.")
+	}
+	switch a.function {
+	case "COUNT":
+		return Field{Type : Integer}, nil
+	case "SUM", "AVG":
+		switch {
+		case field.Type == Decimal:
+			return Field{Type : Decimal}, nil
+		case (a.function == "SUM") && ((field.Type == Integer) || (field.Type == BigInteger)):
+			return Field{Type : BigInteger}, nil
+		}
+		return Field{Type : Float}, nil
+	}
+	return field, nil
+}
+
+//GroupBy groups the rows by the columns, so the aggregates selected are computed for each group :
+//
+//    totals, err := orders.Query().Select("Country", "COUNT(*) AS Orders", "SUM(Total) AS Revenue").
+//        GroupBy("Country").Having("SUM(Total) >", 1000).OrderBy("-Revenue").All()
+//    // totals[0].Object["Revenue"]
+func (q *Query) GroupBy(columns ...string) (*Query) {
+	for _, column := range columns {
+		if !q.isColumn(column) {
+			return q.fail(errors.New("Error : No such field " + column + " in the model or it's owners."))
+		}
+	}
+	q.groupBy = append(q.groupBy, columns...)
+	return q
+}
+
+//Having adds a condition on the groups, joined with AND to the previous ones. It is written like a condition of Where,
+//on a grouped column or an aggregate, eg. Having("COUNT(*) >=", 10).
+func (q *Query) Having(expression string, values ...interface{}) (*Query) {
+	sql, args, err := q.condition(expression, values, true)
+	if err != nil {
+		return q.fail(err)
+	}
+	q.having = append(q.having, condition{false, sql, args})
+	return q
+}
+
+//grouped returns the GROUP BY and HAVING clauses of the query (with a leading space) and the values of the latter.
+func (q *Query) grouped() (string, []interface{}) {
+	if len(q.groupBy) == 0 {
+		return "", nil
+	}
+	clause := " GROUP BY " + quoteAll(dialect, q.groupBy)
+	if len(q.having) == 0 {
+		return clause, nil
+	}
+	having, args := joinConditions(q.having)
+	return clause + " HAVING " + having, args
+}
+
+//Sum returns the sum of the column over the rows of the query, 0 if there are none.
+func (q *Query) Sum(column string) (float64, error) {
+	return q.aggregateFloat("SUM", column)
+}
+
+//Avg returns the average of the column over the rows of the query, 0 if there are none.
+func (q *Query) Avg(column string) (float64, error) {
+	return q.aggregateFloat("AVG", column)
+}
+
+//Min returns the lowest value of the column in the rows of the query, nil if there are none.
+func (q *Query) Min(column string) (interface{}, error) {
+	return q.aggregateValue("MIN", column)
+}
+
+//Max returns the highest value of the column in the rows of the query, nil if there are none.
+func (q *Query) Max(column string) (interface{}, error) {
+	return q.aggregateValue("MAX", column)
+}
+
+//aggregateFloat computes the aggregate of the column as a float64.
+func (q *Query) aggregateFloat(function string, column string) (float64, error) {
+	value, err := q.aggregateValue(function, column)
+	if (err != nil) || (value == nil) {
+		return 0, err
+	}
+	number, ok := valueNumber(value)
+	if !ok {
+		return 0, errors.New("Error : The " + function + " of " + column + " is not a number")
+	}
+	return number, nil
+}
+
+//aggregateValue computes the aggregate function of the column over the rows of the query (its groups are ignored).
+func (q *Query) aggregateValue(function string, column string) (interface{}, error) {
+	a := aggregate{function : function, column : column}
+	field, err := q.aggregateField(a)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := q.where()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.model.query("SELECT " + a.sql() + " FROM " + quote(q.model.Name) + where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var raw interface{}
+	if rows.Next() {
+		err = rows.Scan(&raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	return decode(field, raw)
+}
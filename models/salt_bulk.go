@@ -0,0 +1,238 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+//The most placeholders a statement of BulkInsert has, below the limit of the databases (999 for older SQLite).
+const maxPlaceholders = 999
+
+//objectColumns returns the names of the columns of the model set in the object, in order.
+func (model *Model) objectColumns(object Object) ([]string) {
+	var columns []string
+	for name := range object.Object {
+		if _, ok := model.field(name); ok {
+			columns = append(columns, name)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+//insertValues returns the encoded values of the columns of the object.
+func (model *Model) insertValues(object Object, columns []string) ([]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		field, _ := model.field(column)
+		value, err := encode(field, object.Object[column])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+//BulkInsert inserts the objects as new records with multi-row INSERT statements, in a transaction (or the one the
+//model is bound to). The hooks, timestamps and validators are applied to each object as by AddNewRecord, and no
+//record is inserted if one of them fails.
+func (model *Model) BulkInsert(objects Objects) (error) {
+	if len(objects) == 0 {
+		return nil
+	}
+	if model.tx == nil {
+		return Transaction(func(tx *Tx) error {
+			return tx.Model(model).BulkInsert(objects)
+		})
+	}
+	prepared := make(Objects, len(objects))
+	for i, object := range objects {
+		err := model.runHook(model.BeforeCreate, object)
+		if err != nil {
+			return err
+		}
+		prepared[i] = model.stamped(object, true)
+		err = model.validate(prepared[i], true)
+		if err != nil {
+			return err
+		}
+	}
+	//The objects setting the same columns are inserted together, so the columns they leave out keep their defaults.
+	var sets []string
+	batches := make(map[string]Objects)
+	for _, object := range prepared {
+		columns := model.objectColumns(object)
+		if len(columns) == 0 {
+			return errors.New("Error : The passed Object doesn't have any field of the model " + model.Name + ".")
+		}
+		set := strings.Join(columns, ",")
+		if _, ok := batches[set]; !ok {
+			sets = append(sets, set)
+		}
+		batches[set] = append(batches[set], object)
+	}
+	for _, set := range sets {
+		columns := strings.Split(set, ",")
+		batch := batches[set]
+		rows := maxPlaceholders / len(columns)
+		if rows < 1 {
+			rows = 1
+		}
+		row := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+		for start := 0; start < len(batch); start += rows {
+			end := start + rows
+			if end > len(batch) {
+				end = len(batch)
+			}
+			var args []interface{}
+			for _, object := range batch[start:end] {
+				values, err := model.insertValues(object, columns)
+				if err != nil {
+					return err
+				}
+				args = append(args, values...)
+			}
+			stmt := "INSERT INTO " + quote(model.Name) + " (" + quoteAll(dialect, columns) + ") VALUES " +
+				strings.TrimSuffix(strings.Repeat(row + ",", end - start), ",")
+			_, err := model.exec(stmt, args...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, object := range prepared {
+		err := model.runHook(model.AfterCreate, object)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Upsert inserts the object as a new record or, if a record with the same values of the conflict columns exists
+//(the primary key by default), updates that record with the other values of the object. The conflict columns must
+//have a unique index or be the primary key. The timestamps and validators are applied as by AddNewRecord, but not the
+//hooks ; the created_at of an existing record is kept.
+//
+//    err := stock.Upsert(models.Object{Object : map[string]interface{}{"SKU" : sku, "Count" : 10}}, "SKU")
+func (model *Model) Upsert(object Object, conflict ...string) (error) {
+	if len(conflict) == 0 {
+		conflict = []string{model.PrimaryKey}
+	}
+	isConflict := make(map[string]bool)
+	for _, column := range conflict {
+		if _, ok := model.field(column); !ok {
+			return errors.New("Error : No such field " + column + " in the model or it's owners.")
+		}
+		if _, ok := object.Object[column]; !ok {
+			return errors.New("Error : The passed Object doesn't have the field " + column + ".")
+		}
+		isConflict[column] = true
+	}
+	object = model.stamped(object, true)
+	err := model.validate(object, true)
+	if err != nil {
+		return err
+	}
+	columns := model.objectColumns(object)
+	var update []string
+	for _, column := range columns {
+		if !isConflict[column] && (column != CreatedAtColumn) {
+			update = append(update, column)
+		}
+	}
+	args, err := model.insertValues(object, columns)
+	if err != nil {
+		return err
+	}
+	stmt := "INSERT INTO " + quote(model.Name) + " (" + quoteAll(dialect, columns) + ") VALUES (" +
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")" + dialect.Upsert(conflict, update)
+	_, err = model.exec(stmt, args...)
+	return err
+}
+
+//Update sets the values of the object in all the rows matching the conditions of the query and returns the number
+//of rows changed. The timestamps and validators are applied as by UpdateRecord, but not the hooks. Limit, Offset and
+//the order are ignored.
+//
+//    n, err := users.Query().Where("LastLogin <", cutoff).Update(models.Object{Object : map[string]interface{}{"Active" : false}})
+func (q *Query) Update(object Object) (int, error) {
+	object = q.model.stamped(object, false)
+	err := q.model.validate(object, false)
+	if err != nil {
+		return 0, err
+	}
+	var sets []string
+	var args []interface{}
+	for _, column := range q.model.objectColumns(object) {
+		set, values, err := q.model.FormStatement(column, object.Object[column])
+		if err != nil {
+			return 0, err
+		}
+		sets = append(sets, set)
+		args = append(args, values...)
+	}
+	if len(sets) == 0 {
+		return 0, errors.New("Error : The passed Object doesn't have any field of the model " + q.model.Name + ".")
+	}
+	where, whereArgs, err := q.where()
+	if err != nil {
+		return 0, err
+	}
+	return q.model.affected("UPDATE " + quote(q.model.Name) + " SET " + strings.Join(sets, ",") + where, append(args, whereArgs...)...)
+}
+
+//Delete deletes all the rows matching the conditions of the query, or soft deletes them for a model with SoftDelete,
+//and returns their number. The hooks are not called.
+func (q *Query) Delete() (int, error) {
+	if !q.model.SoftDelete {
+		return q.ForceDelete()
+	}
+	where, args, err := q.where()
+	if err != nil {
+		return 0, err
+	}
+	//The rows already deleted keep the time they were deleted at.
+	if q.withDeleted || q.onlyDeleted {
+		if where == "" {
+			where = " WHERE " + q.model.notDeleted()
+		} else {
+			where += " AND " + q.model.notDeleted()
+		}
+	}
+	return q.model.affected("UPDATE " + quote(q.model.Name) + " SET " + quote(DeletedAtColumn) + "=?" + where, append([]interface{}{now()}, args...)...)
+}
+
+//ForceDelete deletes all the rows matching the conditions of the query, even for a model with SoftDelete, and
+//returns their number.
+func (q *Query) ForceDelete() (int, error) {
+	where, args, err := q.where()
+	if err != nil {
+		return 0, err
+	}
+	return q.model.affected("DELETE FROM " + quote(q.model.Name) + where, args...)
+}
+
+//UpdateWhere sets the values of the object in the rows matching the condition (written like a condition of
+//Query.Where) and returns their number. See Query.Update.
+func (model *Model) UpdateWhere(object Object, condition string, values ...interface{}) (int, error) {
+	return model.Query().Where(condition, values...).Update(object)
+}
+
+//DeleteWhere deletes the rows matching the condition (written like a condition of Query.Where) and returns their
+//number. See Query.Delete.
+func (model *Model) DeleteWhere(condition string, values ...interface{}) (int, error) {
+	return model.Query().Where(condition, values...).Delete()
+}
+
+//affected runs the statement and returns the number of rows it changed.
+func (model *Model) affected(stmt string, args ...interface{}) (int, error) {
+	result, err := model.exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+//The timeout of the queries of the models without a deadline of their own (the QueryTimeout of the Database), 0 for
+//none.
+var queryTimeout time.Duration
+
+//rows are the rows returned by a query of a model. Closing them logs the query with the number of rows read and
+//releases the context it ran with.
+type rows struct {
+	*sql.Rows
+	cancel   context.CancelFunc
+	ctx      context.Context
+	query    string
+	args     []interface{}
+	duration time.Duration
+	read     int64
+	closed   bool
+}
+
+//Next prepares the next row, counting the rows read.
+func (r *rows) Next() (bool) {
+	if r.Rows.Next() {
+		r.read++
+		return true
+	}
+	return false
+}
+
+//Close closes the rows, logs the query and releases their context.
+func (r *rows) Close() (error) {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		logQuery(r.ctx, r.query, r.args, r.duration, r.read, translate(r.Rows.Err()))
+		r.cancel()
+	}
+	return err
+}
+
+//WithContext returns a copy of the model bound to the context : the queries of all its methods (GetRecord,
+//AddNewRecord, Query ...) run with it, so they are cancelled with it. The model passed is left as it is.
+//
+//    objects, err := users.WithContext(r.Context()).GetRecord("City", city)
+func (model *Model) WithContext(ctx context.Context) (*Model) {
+	bound := *model
+	bound.ctx = ctx
+	return &bound
+}
+
+//WithContext runs the query with the context, so it is cancelled with it.
+//
+//    posts, err := posts.Query().WithContext(r.Context()).Where("Author", user).All()
+func (q *Query) WithContext(ctx context.Context) (*Query) {
+	q.model = q.model.WithContext(ctx)
+	return q
+}
+
+//context returns the context a query of the model runs with : the one the model is bound to, or else the one of its
+//transaction, with the QueryTimeout of the database. The cancel function has to be called once the query is done.
+func (model *Model) context() (context.Context, context.CancelFunc) {
+	ctx := model.baseContext()
+	if queryTimeout > 0 {
+		return context.WithTimeout(ctx, queryTimeout)
+	}
+	return ctx, func() {}
+}
+
+//baseContext returns the context the model is bound to, or else the one of its transaction, or else the background.
+func (model *Model) baseContext() (context.Context) {
+	switch {
+	case model.ctx != nil:
+		return model.ctx
+	case (model.tx != nil) && (model.tx.ctx != nil):
+		return model.tx.ctx
+	}
+	return context.Background()
+}
+
+//GetRecordContext is GetRecord with a context.
+func (model *Model) GetRecordContext(ctx context.Context, field string, value interface{}) (Objects, error) {
+	return model.WithContext(ctx).GetRecord(field, value)
+}
+
+//GetAllContext is GetAll with a context.
+func (model *Model) GetAllContext(ctx context.Context) (error) {
+	bound := model.WithContext(ctx)
+	err := bound.GetAll()
+	model.Objects = bound.Objects
+	return err
+}
+
+//AddNewRecordContext is AddNewRecord with a context.
+func (model *Model) AddNewRecordContext(ctx context.Context, object Object) (interface{}, Object, error) {
+	return model.WithContext(ctx).AddNewRecord(object)
+}
+
+//UpdateRecordContext is UpdateRecord with a context.
+func (model *Model) UpdateRecordContext(ctx context.Context, object Object, fieldName string, value interface{}) (int, error) {
+	return model.WithContext(ctx).UpdateRecord(object, fieldName, value)
+}
+
+//DeleteRecordContext is DeleteRecord with a context.
+func (model *Model) DeleteRecordContext(ctx context.Context, field string, value interface{}) (int, error) {
+	return model.WithContext(ctx).DeleteRecord(field, value)
+}
+
+//DoQueryContext is DoQueryArgs with a context.
+func (model *Model) DoQueryContext(ctx context.Context, query string, args ...interface{}) (Objects, error) {
+	return model.WithContext(ctx).DoQueryArgs(query, args...)
+}
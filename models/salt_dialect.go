@@ -0,0 +1,966 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"github.com/go-sql-driver/mysql"
+)
+
+//Dialect contains what differs between the SQL databases supported by the models package. The dialect is chosen
+//with the Driver field of the Database configuration :
+//
+//    mysql            -  MySQL / MariaDB (github.com/go-sql-driver/mysql, the default)
+//    postgres, pgx    -  PostgreSQL (github.com/lib/pq or github.com/jackc/pgx/v5/stdlib)
+//    sqlite3, sqlite  -  SQLite (github.com/mattn/go-sqlite3 or modernc.org/sqlite)
+//
+//The MySQL driver is imported by the models package. The drivers of the other databases should be imported by the
+//web-app itself, eg. import _ "github.com/lib/pq".
+//
+//The queries in the models package are written with ? placeholders, which are converted to the placeholders of the
+//dialect before they are run.
+type Dialect interface {
+	//DriverName returns the database/sql driver name.
+	DriverName() string
+	//DSN forms the data source name from the database configuration (when it has no DSN of its own).
+	DSN(datab Database) string
+	//Quote quotes a table or column name.
+	Quote(identifier string) string
+	//Placeholder returns the n-th (starting from 1) placeholder of a statement.
+	Placeholder(n int) string
+	//ColumnType returns the column type of the field.
+	ColumnType(field Field) string
+	//AutoIncrement returns the column definition of an auto incremented column of the given type and whether that
+	//definition declares the column as the primary key.
+	AutoIncrement(columnType string) (string, bool)
+	//TablesQuery returns the query listing the names of the tables in the database.
+	TablesQuery() string
+	//Savepoints tells whether the database supports savepoints, which nested transactions need.
+	Savepoints() bool
+	//Columns returns the columns of a table in the database, with the types spelled like ColumnType does, and their
+	//defaults, checks and foreign keys.
+	Columns(q Queryer, table string) ([]Column, error)
+	//AddColumn returns the statement adding the column to the table.
+	AddColumn(table string, column Column) string
+	//DropColumn returns the statement removing the column from the table.
+	DropColumn(table string, column string) string
+	//AlterColumn returns the statements changing a column of the table from its live definition to the declared one.
+	AlterColumn(table string, live Column, declared Column) ([]string, error)
+	//SetPrimaryKey returns the statements changing the primary key of the table from the live columns to the
+	//declared ones. Either can be empty.
+	SetPrimaryKey(table string, live []string, declared []string) ([]string, error)
+	//Indexes returns the indexes of a table in the database, except the primary key and those made for UNIQUE
+	//constraints.
+	Indexes(q Queryer, table string) ([]Index, error)
+	//DropIndex returns the statement dropping an index of the table.
+	DropIndex(table string, name string) string
+	//Upsert returns the clause ending an INSERT statement which updates the update columns of the existing row
+	//instead when a row with the same conflict columns exists.
+	Upsert(conflict []string, update []string) string
+	//Returning returns the clause ending an INSERT statement which returns the column of the inserted row, or ""
+	//if the driver gives the auto incremented primary key with LastInsertId.
+	Returning(column string) string
+	//TranslateError returns a DatabaseError of ErrDuplicate or ErrConstraint for the errors of the driver which are
+	//constraint violations, and the other errors as they are.
+	TranslateError(err error) error
+}
+
+//Queryer is what the dialects need to introspect the database. *sql.DB and *sql.Tx are Queryers.
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//Global Private variable that contains the dialects by the Driver names.
+var dialects = map[string]Dialect{
+	"mysql":    mysqlDialect{},
+	"postgres": postgresDialect{"postgres"},
+	"pgx":      postgresDialect{"pgx"},
+	"sqlite3":  sqliteDialect{"sqlite3"},
+	"sqlite":   sqliteDialect{"sqlite"},
+}
+
+//The dialect of the configured database.
+var dialect Dialect = mysqlDialect{}
+
+//RegisterDialect adds a dialect for the Driver name, to support another database or driver.
+func RegisterDialect(driver string, d Dialect) (error) {
+	if _, ok := dialects[driver]; ok {
+		return errors.New("Error : A dialect is already registered for the driver " + driver)
+	}
+	dialects[driver] = d
+	return nil
+}
+
+//GetDialect returns the dialect of the configured database.
+func GetDialect() (Dialect) {
+	return dialect
+}
+
+//getDialect returns the dialect for the Driver of the database configuration.
+func getDialect(datab Database) (Dialect, error) {
+	driver := datab.Driver
+	if driver == "" {
+		driver = "mysql"
+	}
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, errors.New("Error : Unknown database driver " + driver)
+	}
+	return d, nil
+}
+
+//rebind converts the ? placeholders in the query to the ones of the dialect. Question marks in quoted strings and
+//identifiers are left alone.
+func rebind(query string) (string) {
+	if dialect.Placeholder(1) == "?" {
+		return query
+	}
+	var rebound strings.Builder
+	var quote byte
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			n++
+			rebound.WriteString(dialect.Placeholder(n))
+			continue
+		}
+		rebound.WriteByte(c)
+	}
+	return rebound.String()
+}
+
+//quote quotes a table or column name with the dialect of the configured database.
+func quote(identifier string) (string) {
+	return dialect.Quote(identifier)
+}
+
+//quoteAll quotes the column names and joins them with commas.
+func quoteAll(d Dialect, columns []string) (string) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = d.Quote(column)
+	}
+	return strings.Join(quoted, ",")
+}
+
+//scanOne runs a query returning a single value and scans it into dest.
+func scanOne(q Queryer, dest interface{}, query string, args ...interface{}) (error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return rows.Scan(dest)
+}
+
+//readIndexes runs a query returning the name, uniqueness and column of the columns of indexes, ordered by index and
+//position in the index, and returns the indexes.
+func readIndexes(q Queryer, query string, args ...interface{}) ([]Index, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []Index
+	for rows.Next() {
+		var name, column string
+		var unique bool
+		err = rows.Scan(&name, &unique, &column)
+		if err != nil {
+			return nil, err
+		}
+		if (len(indexes) == 0) || (indexes[len(indexes)-1].Name != name) {
+			indexes = append(indexes, Index{Name : name, Unique : unique})
+		}
+		indexes[len(indexes)-1].Columns = append(indexes[len(indexes)-1].Columns, column)
+	}
+	return indexes, rows.Err()
+}
+
+//readChecks runs a query returning the name and SQL of the check constraints of a table, and returns the SQL of the
+//checks by name.
+func readChecks(q Queryer, query string, args ...interface{}) (map[string]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	checks := make(map[string]string)
+	for rows.Next() {
+		var name, check string
+		err = rows.Scan(&name, &check)
+		if err != nil {
+			return nil, err
+		}
+		checks[name] = check
+	}
+	return checks, rows.Err()
+}
+
+//readReferences runs a query returning the column, referred table and column, and the ON UPDATE and ON DELETE actions
+//of the foreign keys of a table, and returns the references by column.
+func readReferences(q Queryer, query string, args ...interface{}) (map[string]*Reference, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	references := make(map[string]*Reference)
+	for rows.Next() {
+		var column, onUpdate, onDelete string
+		reference := &Reference{}
+		err = rows.Scan(&column, &reference.Table, &reference.Column, &onUpdate, &onDelete)
+		if err != nil {
+			return nil, err
+		}
+		reference.OnUpdate, reference.OnDelete = Action(strings.ToUpper(onUpdate)), Action(strings.ToUpper(onDelete))
+		references[column] = reference
+	}
+	return references, rows.Err()
+}
+
+//hostPort joins the host and port of the database configuration.
+func hostPort(datab Database, defaultPort string) (string) {
+	host, port := datab.Host, datab.Port
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return host + ":" + port
+}
+
+//mysqlDialect is the Dialect of MySQL and MariaDB.
+type mysqlDialect struct{}
+
+func (mysqlDialect) DriverName() string { return "mysql" }
+
+//DSN makes MySQL count the rows an UPDATE matched rather than those it changed (clientFoundRows), as the other
+//databases do, so an update leaving a row as it was isn't taken for no row matching.
+func (mysqlDialect) DSN(datab Database) string {
+	if datab.Host == "" && datab.Port == "" {
+		return datab.Username + ":" + datab.Password + "@/" + datab.Database + "?clientFoundRows=true"
+	}
+	return datab.Username + ":" + datab.Password + "@tcp(" + hostPort(datab, "3306") + ")/" + datab.Database + "?clientFoundRows=true"
+}
+
+func (mysqlDialect) Quote(identifier string) string {
+	return "`" + strings.Replace(identifier, "`", "``", -1) + "`"
+}
+
+func (mysqlDialect) Placeholder(n int) string { return "?" }
+
+func (mysqlDialect) ColumnType(field Field) string {
+	switch field.Type {
+	case CharField:
+		return "varchar(" + strconv.Itoa(field.length()) + ")"
+	case TextField:
+		return "TEXT"
+	case Integer:
+		return "INT"
+	case Float:
+		return "DOUBLE"
+	case Boolean:
+		return "BOOL"
+	case DateTime:
+		return "DATETIME"
+	case Date:
+		return "DATE"
+	case Time:
+		return "TIME"
+	case Decimal:
+		return "decimal(" + strconv.Itoa(field.precision()) + "," + strconv.Itoa(field.Scale) + ")"
+	case BigInteger:
+		return "BIGINT"
+	case JSON:
+		return "JSON"
+	case Blob:
+		return "LONGBLOB"
+	case UUID:
+		return "char(36)"
+	}
+	return ""
+}
+
+func (mysqlDialect) AutoIncrement(columnType string) (string, bool) {
+	return columnType + " AUTO_INCREMENT", false
+}
+
+func (mysqlDialect) TablesQuery() string { return "SHOW TABLES" }
+
+func (mysqlDialect) Savepoints() bool { return true }
+
+//The check constraints are read from CHECK_CONSTRAINTS, which MySQL has since 8.0.16 (and MariaDB since 10.2).
+func (d mysqlDialect) Columns(q Queryer, table string) ([]Column, error) {
+	checks, err := readChecks(q, "SELECT c.CONSTRAINT_NAME, c.CHECK_CLAUSE FROM information_schema.TABLE_CONSTRAINTS t"+
+		" JOIN information_schema.CHECK_CONSTRAINTS c ON c.CONSTRAINT_SCHEMA = t.CONSTRAINT_SCHEMA AND c.CONSTRAINT_NAME = t.CONSTRAINT_NAME"+
+		" WHERE t.TABLE_SCHEMA = DATABASE() AND t.TABLE_NAME = ? AND t.CONSTRAINT_TYPE = 'CHECK'", table)
+	if err != nil {
+		return nil, err
+	}
+	references, err := readReferences(q, "SELECT k.COLUMN_NAME, k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE"+
+		" FROM information_schema.KEY_COLUMN_USAGE k"+
+		" JOIN information_schema.REFERENTIAL_CONSTRAINTS r ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME"+
+		" WHERE k.TABLE_SCHEMA = DATABASE() AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL", table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query("SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA, COLUMN_DEFAULT FROM information_schema.COLUMNS"+
+		" WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []Column
+	for rows.Next() {
+		var name, columnType, nullable, key, extra string
+		var columnDefault sql.NullString
+		err = rows.Scan(&name, &columnType, &nullable, &key, &extra, &columnDefault)
+		if err != nil {
+			return nil, err
+		}
+		columnType = strings.ToLower(columnType)
+		switch {
+		case columnType == "tinyint(1)":
+			columnType = "bool"
+		case strings.HasPrefix(columnType, "int("), strings.HasPrefix(columnType, "bigint("):
+			//Display widths are not part of the type.
+			columnType = columnType[:strings.Index(columnType, "(")]
+		}
+		columns = append(columns, Column{
+			Name:          name,
+			Type:          columnType,
+			NotNull:       nullable == "NO",
+			Unique:        key == "UNI",
+			PrimaryKey:    key == "PRI",
+			AutoIncrement: strings.Contains(extra, "auto_increment"),
+			Default:       mysqlDefault(columnDefault, columnType, extra),
+			Check:         checks[checkName(table, name)],
+			References:    references[name],
+		})
+	}
+	return columns, rows.Err()
+}
+
+//mysqlDefault returns the SQL of the COLUMN_DEFAULT of a column. MySQL gives the values of the literals (booleans as
+//1 or 0) and marks the expressions as DEFAULT_GENERATED, MariaDB quotes the strings, writes the functions with
+//parentheses and gives no default as NULL.
+func mysqlDefault(value sql.NullString, columnType string, extra string) (string) {
+	switch {
+	case !value.Valid, value.String == "NULL":
+		return ""
+	case strings.Contains(extra, "DEFAULT_GENERATED"), strings.EqualFold(value.String, "CURRENT_TIMESTAMP"),
+		strings.HasSuffix(value.String, "()"), strings.HasPrefix(value.String, "'"):
+		return value.String
+	case columnType == "bool":
+		if value.String == "0" {
+			return "FALSE"
+		}
+		return "TRUE"
+	}
+	for _, numeric := range []string{"int", "bigint", "smallint", "tinyint", "mediumint", "decimal", "double", "float"} {
+		if strings.HasPrefix(columnType, numeric) {
+			return value.String
+		}
+	}
+	return defaultSQL(value.String)
+}
+
+func (d mysqlDialect) AddColumn(table string, column Column) string {
+	stmt := "ALTER TABLE " + d.Quote(table) + " ADD COLUMN " + columnDefinition(d, table, column)
+	if column.References != nil {
+		stmt += ", ADD " + foreignKey(d, table, column)
+	}
+	return stmt
+}
+
+func (d mysqlDialect) DropColumn(table string, column string) string {
+	return "ALTER TABLE " + d.Quote(table) + " DROP COLUMN " + d.Quote(column)
+}
+
+func (d mysqlDialect) AlterColumn(table string, live Column, declared Column) ([]string, error) {
+	modify := declared
+	modify.Unique, modify.Check = false, ""
+	stmts := []string{"ALTER TABLE " + d.Quote(table) + " MODIFY COLUMN " + columnDefinition(d, table, modify)}
+	if declared.Unique && !live.Unique {
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" ADD UNIQUE ("+d.Quote(declared.Name)+")")
+	}
+	if live.Unique && !declared.Unique {
+		//MySQL names the index of a single column UNIQUE constraint after the column.
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" DROP INDEX "+d.Quote(live.Name))
+	}
+	if !sameSQL(live.Check, declared.Check) {
+		if live.Check != "" {
+			stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" DROP CHECK "+d.Quote(checkName(table, live.Name)))
+		}
+		if declared.Check != "" {
+			stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" ADD CONSTRAINT "+d.Quote(checkName(table, declared.Name))+
+				" CHECK ("+declared.Check+")")
+		}
+	}
+	if !sameReference(live.References, declared.References) {
+		if live.References != nil {
+			stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" DROP FOREIGN KEY "+d.Quote(foreignKeyName(table, live.Name)))
+		}
+		if declared.References != nil {
+			stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" ADD "+foreignKey(d, table, declared))
+		}
+	}
+	return stmts, nil
+}
+
+func (d mysqlDialect) Indexes(q Queryer, table string) ([]Index, error) {
+	return readIndexes(q, "SELECT INDEX_NAME, NON_UNIQUE = 0, COLUMN_NAME FROM information_schema.STATISTICS"+
+		" WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME <> 'PRIMARY'"+
+		" AND NOT (NON_UNIQUE = 0 AND INDEX_NAME = COLUMN_NAME AND SEQ_IN_INDEX = 1)"+
+		" ORDER BY INDEX_NAME, SEQ_IN_INDEX", table)
+}
+
+func (d mysqlDialect) DropIndex(table string, name string) string {
+	return "DROP INDEX " + d.Quote(name) + " ON " + d.Quote(table)
+}
+
+func (d mysqlDialect) Upsert(conflict []string, update []string) string {
+	if len(update) == 0 {
+		//Setting a conflict column to itself leaves the row as it is.
+		update = conflict[:1]
+	}
+	var sets []string
+	for _, column := range update {
+		sets = append(sets, d.Quote(column)+"=VALUES("+d.Quote(column)+")")
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ",")
+}
+
+func (mysqlDialect) Returning(column string) string { return "" }
+
+//The error numbers of MySQL : 1062 and 1586 are duplicate entries, 1048 a NULL in a NOT NULL column, 1216, 1217, 1451
+//and 1452 foreign keys and 3819 checks.
+func (mysqlDialect) TranslateError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+	switch mysqlErr.Number {
+	case 1062, 1586:
+		return databaseError(ErrDuplicate, err)
+	case 1048, 1216, 1217, 1451, 1452, 3819:
+		return databaseError(ErrConstraint, err)
+	}
+	return err
+}
+
+func (d mysqlDialect) SetPrimaryKey(table string, live []string, declared []string) ([]string, error) {
+	var stmts []string
+	if len(live) > 0 {
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" DROP PRIMARY KEY")
+	}
+	if len(declared) > 0 {
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" ADD PRIMARY KEY ("+quoteAll(d, declared)+")")
+	}
+	return stmts, nil
+}
+
+//postgresDialect is the Dialect of PostgreSQL.
+type postgresDialect struct {
+	driver string
+}
+
+func (d postgresDialect) DriverName() string { return d.driver }
+
+func (postgresDialect) DSN(datab Database) string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(datab.Username, datab.Password),
+		Host:   hostPort(datab, "5432"),
+		Path:   "/" + datab.Database,
+	}
+	sslmode := datab.SSLMode
+	if (sslmode == "") && isLocal(datab.Host) {
+		//lib/pq requires SSL by default, which local servers usually don't have.
+		sslmode = "disable"
+	}
+	if sslmode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {sslmode}}.Encode()
+	}
+	return dsn.String()
+}
+
+//isLocal tells whether the host is the local machine.
+func isLocal(host string) (bool) {
+	switch host {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+func (postgresDialect) Quote(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+func (postgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgresDialect) ColumnType(field Field) string {
+	switch field.Type {
+	case CharField:
+		return "varchar(" + strconv.Itoa(field.length()) + ")"
+	case TextField:
+		return "TEXT"
+	case Integer:
+		return "INTEGER"
+	case Float:
+		return "DOUBLE PRECISION"
+	case Boolean:
+		return "BOOLEAN"
+	case DateTime:
+		return "TIMESTAMP"
+	case Date:
+		return "DATE"
+	case Time:
+		return "TIME"
+	case Decimal:
+		return "numeric(" + strconv.Itoa(field.precision()) + "," + strconv.Itoa(field.Scale) + ")"
+	case BigInteger:
+		return "BIGINT"
+	case JSON:
+		return "JSONB"
+	case Blob:
+		return "BYTEA"
+	case UUID:
+		return "UUID"
+	}
+	return ""
+}
+
+func (postgresDialect) AutoIncrement(columnType string) (string, bool) {
+	if columnType == "BIGINT" {
+		return "BIGSERIAL", false
+	}
+	return "SERIAL", false
+}
+
+func (postgresDialect) TablesQuery() string {
+	return "SELECT tablename FROM pg_catalog.pg_tables WHERE schemaname = current_schema()"
+}
+
+func (postgresDialect) Savepoints() bool { return true }
+
+func (d postgresDialect) Columns(q Queryer, table string) ([]Column, error) {
+	checks, err := readChecks(q, "SELECT c.constraint_name, c.check_clause FROM information_schema.table_constraints t"+
+		" JOIN information_schema.check_constraints c ON c.constraint_schema = t.constraint_schema AND c.constraint_name = t.constraint_name"+
+		" WHERE t.table_schema = current_schema() AND t.table_name = $1 AND t.constraint_type = 'CHECK'", table)
+	if err != nil {
+		return nil, err
+	}
+	references, err := readReferences(q, "SELECT k.column_name, u.table_name, u.column_name, r.update_rule, r.delete_rule"+
+		" FROM information_schema.referential_constraints r"+
+		" JOIN information_schema.key_column_usage k ON k.constraint_schema = r.constraint_schema AND k.constraint_name = r.constraint_name"+
+		" JOIN information_schema.constraint_column_usage u ON u.constraint_schema = r.constraint_schema AND u.constraint_name = r.constraint_name"+
+		" WHERE k.table_schema = current_schema() AND k.table_name = $1", table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query("SELECT c.column_name, c.data_type, COALESCE(c.character_maximum_length, 0),"+
+		" COALESCE(c.numeric_precision, 0), COALESCE(c.numeric_scale, 0), c.is_nullable,"+
+		" COALESCE(c.column_default, ''),"+
+		" EXISTS (SELECT 1 FROM information_schema.table_constraints t"+
+		"  JOIN information_schema.key_column_usage k ON k.constraint_name = t.constraint_name AND k.table_schema = t.table_schema"+
+		"  WHERE t.table_schema = c.table_schema AND t.table_name = c.table_name AND k.column_name = c.column_name"+
+		"  AND t.constraint_type = 'UNIQUE'"+
+		"  AND (SELECT COUNT(*) FROM information_schema.key_column_usage k2 WHERE k2.constraint_name = t.constraint_name AND k2.table_schema = t.table_schema) = 1),"+
+		" EXISTS (SELECT 1 FROM information_schema.table_constraints t"+
+		"  JOIN information_schema.key_column_usage k ON k.constraint_name = t.constraint_name AND k.table_schema = t.table_schema"+
+		"  WHERE t.table_schema = c.table_schema AND t.table_name = c.table_name AND k.column_name = c.column_name"+
+		"  AND t.constraint_type = 'PRIMARY KEY')"+
+		" FROM information_schema.columns c WHERE c.table_schema = current_schema() AND c.table_name = $1"+
+		" ORDER BY c.ordinal_position", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []Column
+	for rows.Next() {
+		var name, dataType, nullable, columnDefault string
+		var length, precision, scale int
+		var unique, primaryKey bool
+		err = rows.Scan(&name, &dataType, &length, &precision, &scale, &nullable, &columnDefault, &unique, &primaryKey)
+		if err != nil {
+			return nil, err
+		}
+		switch dataType {
+		case "character varying":
+			dataType = "varchar(" + strconv.Itoa(length) + ")"
+		case "numeric":
+			dataType = "numeric(" + strconv.Itoa(precision) + "," + strconv.Itoa(scale) + ")"
+		case "timestamp without time zone":
+			dataType = "timestamp"
+		case "time without time zone":
+			dataType = "time"
+		}
+		autoIncrement := strings.HasPrefix(columnDefault, "nextval(")
+		if autoIncrement || strings.HasPrefix(columnDefault, "NULL::") {
+			//The sequence of a serial column isn't a default of the model, nor is DEFAULT NULL.
+			columnDefault = ""
+		}
+		columns = append(columns, Column{
+			Name:          name,
+			Type:          dataType,
+			NotNull:       nullable == "NO",
+			Unique:        unique,
+			PrimaryKey:    primaryKey,
+			AutoIncrement: autoIncrement,
+			Default:       columnDefault,
+			Check:         checks[checkName(table, name)],
+			References:    references[name],
+		})
+	}
+	return columns, rows.Err()
+}
+
+func (d postgresDialect) AddColumn(table string, column Column) string {
+	stmt := "ALTER TABLE " + d.Quote(table) + " ADD COLUMN " + columnDefinition(d, table, column)
+	if column.References != nil {
+		stmt += ", ADD " + foreignKey(d, table, column)
+	}
+	return stmt
+}
+
+func (d postgresDialect) DropColumn(table string, column string) string {
+	return "ALTER TABLE " + d.Quote(table) + " DROP COLUMN " + d.Quote(column)
+}
+
+func (d postgresDialect) AlterColumn(table string, live Column, declared Column) ([]string, error) {
+	prefix := "ALTER TABLE " + d.Quote(table) + " "
+	column := d.Quote(declared.Name)
+	var stmts []string
+	if !sameType(live.Type, declared.Type) {
+		stmts = append(stmts, prefix+"ALTER COLUMN "+column+" TYPE "+declared.Type+" USING "+column+"::"+declared.Type)
+	}
+	if declared.NotNull && !live.NotNull {
+		stmts = append(stmts, prefix+"ALTER COLUMN "+column+" SET NOT NULL")
+	}
+	if live.NotNull && !declared.NotNull && !live.PrimaryKey {
+		stmts = append(stmts, prefix+"ALTER COLUMN "+column+" DROP NOT NULL")
+	}
+	//PostgreSQL names single column UNIQUE constraints <table>_<column>_key.
+	if declared.Unique && !live.Unique {
+		stmts = append(stmts, prefix+"ADD CONSTRAINT "+d.Quote(table+"_"+declared.Name+"_key")+" UNIQUE ("+column+")")
+	}
+	if live.Unique && !declared.Unique {
+		stmts = append(stmts, prefix+"DROP CONSTRAINT "+d.Quote(table+"_"+live.Name+"_key"))
+	}
+	if !sameSQL(live.Default, declared.Default) {
+		if declared.Default == "" {
+			stmts = append(stmts, prefix+"ALTER COLUMN "+column+" DROP DEFAULT")
+		} else {
+			stmts = append(stmts, prefix+"ALTER COLUMN "+column+" SET DEFAULT "+declared.Default)
+		}
+	}
+	if !sameSQL(live.Check, declared.Check) {
+		if live.Check != "" {
+			stmts = append(stmts, prefix+"DROP CONSTRAINT "+d.Quote(checkName(table, live.Name)))
+		}
+		if declared.Check != "" {
+			stmts = append(stmts, prefix+"ADD CONSTRAINT "+d.Quote(checkName(table, declared.Name))+" CHECK ("+declared.Check+")")
+		}
+	}
+	if !sameReference(live.References, declared.References) {
+		if live.References != nil {
+			stmts = append(stmts, prefix+"DROP CONSTRAINT "+d.Quote(foreignKeyName(table, live.Name)))
+		}
+		if declared.References != nil {
+			stmts = append(stmts, prefix+"ADD "+foreignKey(d, table, declared))
+		}
+	}
+	if live.AutoIncrement != declared.AutoIncrement {
+		return nil, errors.New("Error : Changing the auto increment of " + table + "." + declared.Name + " is not supported")
+	}
+	return stmts, nil
+}
+
+func (d postgresDialect) Indexes(q Queryer, table string) ([]Index, error) {
+	return readIndexes(q, "SELECT i.relname, ix.indisunique, a.attname FROM pg_catalog.pg_class t"+
+		" JOIN pg_catalog.pg_index ix ON ix.indrelid = t.oid"+
+		" JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid"+
+		" JOIN pg_catalog.pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)"+
+		" WHERE t.relname = $1 AND t.relnamespace = current_schema()::regnamespace AND NOT ix.indisprimary"+
+		" AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint c WHERE c.conindid = ix.indexrelid)"+
+		" ORDER BY i.relname, array_position(ix.indkey::int2[], a.attnum)", table)
+}
+
+func (d postgresDialect) DropIndex(table string, name string) string {
+	return "DROP INDEX " + d.Quote(name)
+}
+
+func (d postgresDialect) Upsert(conflict []string, update []string) string {
+	return onConflict(d, conflict, update)
+}
+
+//The drivers of PostgreSQL don't support LastInsertId.
+func (d postgresDialect) Returning(column string) string {
+	return " RETURNING " + d.Quote(column)
+}
+
+//The errors of lib/pq and pgx give the SQLSTATE code, where the class 23 is the integrity constraint violations. pgx
+//wraps them in other errors.
+func (postgresDialect) TranslateError(err error) error {
+	var coded interface{ SQLState() string }
+	if !errors.As(err, &coded) {
+		return err
+	}
+	switch code := coded.SQLState(); {
+	case code == "23505":
+		return databaseError(ErrDuplicate, err)
+	case strings.HasPrefix(code, "23"):
+		return databaseError(ErrConstraint, err)
+	}
+	return err
+}
+
+func (d postgresDialect) SetPrimaryKey(table string, live []string, declared []string) ([]string, error) {
+	var stmts []string
+	if len(live) > 0 {
+		//The primary key constraint is named <table>_pkey by default.
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" DROP CONSTRAINT "+d.Quote(table+"_pkey"))
+	}
+	if len(declared) > 0 {
+		stmts = append(stmts, "ALTER TABLE "+d.Quote(table)+" ADD PRIMARY KEY ("+quoteAll(d, declared)+")")
+	}
+	return stmts, nil
+}
+
+//sqliteDialect is the Dialect of SQLite. The Database field of the configuration is the path of the database file.
+type sqliteDialect struct {
+	driver string
+}
+
+func (d sqliteDialect) DriverName() string { return d.driver }
+
+//DSN turns the foreign keys on, as SQLite doesn't enforce them by default.
+func (d sqliteDialect) DSN(datab Database) string {
+	separator := "?"
+	if strings.Contains(datab.Database, "?") {
+		separator = "&"
+	}
+	if d.driver == "sqlite" {
+		return datab.Database + separator + "_pragma=foreign_keys(1)"
+	}
+	return datab.Database + separator + "_foreign_keys=1"
+}
+
+func (sqliteDialect) Quote(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+func (sqliteDialect) Placeholder(n int) string { return "?" }
+
+func (sqliteDialect) ColumnType(field Field) string {
+	switch field.Type {
+	case CharField:
+		return "VARCHAR(" + strconv.Itoa(field.length()) + ")"
+	case TextField:
+		return "TEXT"
+	case Integer:
+		return "INTEGER"
+	case Float:
+		return "REAL"
+	case Boolean:
+		return "BOOLEAN"
+	case DateTime:
+		return "DATETIME"
+	case Date:
+		return "DATE"
+	case Time:
+		return "TIME"
+	case Decimal:
+		return "DECIMAL(" + strconv.Itoa(field.precision()) + "," + strconv.Itoa(field.Scale) + ")"
+	case BigInteger:
+		//SQLite integers are 64 bits wide, and only INTEGER columns can auto increment.
+		return "INTEGER"
+	case JSON:
+		//A JSON column would have the NUMERIC affinity.
+		return "TEXT"
+	case Blob:
+		return "BLOB"
+	case UUID:
+		return "CHAR(36)"
+	}
+	return ""
+}
+
+//SQLite only auto increments an INTEGER PRIMARY KEY column.
+func (sqliteDialect) AutoIncrement(columnType string) (string, bool) {
+	return "INTEGER PRIMARY KEY AUTOINCREMENT", true
+}
+
+func (sqliteDialect) TablesQuery() string {
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+}
+
+func (sqliteDialect) Savepoints() bool { return true }
+
+func (d sqliteDialect) Columns(q Queryer, table string) ([]Column, error) {
+	var create string
+	err := scanOne(q, &create, "SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if err != nil {
+		return nil, err
+	}
+	//Single column unique indexes made for UNIQUE constraints.
+	unique := make(map[string]bool)
+	indexes, err := q.Query("SELECT il.name, ii.name FROM pragma_index_list(?) il, pragma_index_info(il.name) ii"+
+		" WHERE il.\"unique\" = 1 AND il.origin = 'u'"+
+		" AND (SELECT COUNT(*) FROM pragma_index_info(il.name)) = 1", table)
+	if err != nil {
+		return nil, err
+	}
+	for indexes.Next() {
+		var index, column string
+		if err := indexes.Scan(&index, &column); err != nil {
+			indexes.Close()
+			return nil, err
+		}
+		unique[column] = true
+	}
+	indexes.Close()
+	references, err := readReferences(q, "SELECT \"from\", \"table\", COALESCE(\"to\", ''), on_update, on_delete FROM pragma_foreign_key_list(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.Query("SELECT name, type, \"notnull\", pk, dflt_value FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []Column
+	for rows.Next() {
+		var name, columnType string
+		var notNull, pk int
+		var columnDefault sql.NullString
+		err = rows.Scan(&name, &columnType, &notNull, &pk, &columnDefault)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, Column{
+			Name:          name,
+			Type:          columnType,
+			NotNull:       notNull != 0,
+			Unique:        unique[name],
+			PrimaryKey:    pk != 0,
+			AutoIncrement: pk != 0 && strings.Contains(strings.ToUpper(create), "AUTOINCREMENT"),
+			Default:       columnDefault.String,
+			Check:         sqliteCheck(create, d.Quote(checkName(table, name))),
+			References:    references[name],
+		})
+	}
+	return columns, rows.Err()
+}
+
+//sqliteCheck returns the SQL of the check constraint named name in the CREATE TABLE statement of a table, which SQLite
+//keeps as it was written (with the columns added since).
+func sqliteCheck(create string, name string) (string) {
+	start := strings.Index(create, "CONSTRAINT " + name + " CHECK (")
+	if start < 0 {
+		return ""
+	}
+	start += len("CONSTRAINT " + name + " CHECK (")
+	depth, quoted := 1, false
+	for i := start; i < len(create); i++ {
+		switch {
+		case create[i] == '\'':
+			quoted = !quoted
+		case quoted:
+		case create[i] == '(':
+			depth++
+		case create[i] == ')':
+			depth--
+			if depth == 0 {
+				return create[start:i]
+			}
+		}
+	}
+	return ""
+}
+
+//SQLite can't add UNIQUE or PRIMARY KEY columns, nor NOT NULL columns without a default.
+func (d sqliteDialect) AddColumn(table string, column Column) string {
+	column.Unique, column.PrimaryKey, column.AutoIncrement = false, false, false
+	stmt := "ALTER TABLE " + d.Quote(table) + " ADD COLUMN " + columnDefinition(d, table, column)
+	if column.References != nil {
+		//SQLite can't add a table constraint, the foreign key is declared with the column.
+		stmt += " " + references(d, column.References)
+	}
+	return stmt
+}
+
+func (d sqliteDialect) DropColumn(table string, column string) string {
+	return "ALTER TABLE " + d.Quote(table) + " DROP COLUMN " + d.Quote(column)
+}
+
+//SQLite can't alter a column, the table is rebuilt.
+func (d sqliteDialect) AlterColumn(table string, live Column, declared Column) ([]string, error) {
+	return nil, ErrRebuildTable
+}
+
+func (d sqliteDialect) Indexes(q Queryer, table string) ([]Index, error) {
+	return readIndexes(q, "SELECT il.name, il.\"unique\", ii.name FROM pragma_index_list(?) il, pragma_index_info(il.name) ii"+
+		" WHERE il.origin = 'c' ORDER BY il.name, ii.seqno", table)
+}
+
+func (d sqliteDialect) DropIndex(table string, name string) string {
+	return "DROP INDEX " + d.Quote(name)
+}
+
+func (d sqliteDialect) Upsert(conflict []string, update []string) string {
+	return onConflict(d, conflict, update)
+}
+
+func (sqliteDialect) Returning(column string) string { return "" }
+
+//The drivers of SQLite have error types of their own, but all of them keep the message of SQLite, like
+//"UNIQUE constraint failed: Users.Email".
+func (sqliteDialect) TranslateError(err error) error {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "UNIQUE constraint failed"):
+		return databaseError(ErrDuplicate, err)
+	case strings.Contains(message, "constraint failed"):
+		return databaseError(ErrConstraint, err)
+	}
+	return err
+}
+
+//onConflict returns the ON CONFLICT clause of PostgreSQL and SQLite, where excluded is the row which wasn't inserted.
+func onConflict(d Dialect, conflict []string, update []string) string {
+	clause := " ON CONFLICT (" + quoteAll(d, conflict) + ") DO "
+	if len(update) == 0 {
+		return clause + "NOTHING"
+	}
+	var sets []string
+	for _, column := range update {
+		sets = append(sets, d.Quote(column)+"=excluded."+d.Quote(column))
+	}
+	return clause + "UPDATE SET " + strings.Join(sets, ",")
+}
+
+//SQLite can't move the primary key either.
+func (d sqliteDialect) SetPrimaryKey(table string, live []string, declared []string) ([]string, error) {
+	return nil, ErrRebuildTable
+}
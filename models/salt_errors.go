@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+)
+
+//ErrNotFound is returned when no record matches, eg. by Query.First, or by UpdateRecord and DeleteRecord for a primary
+//key.
+var ErrNotFound error = errors.New("Error : No record found")
+
+//ErrDuplicate is the Kind of the DatabaseError returned when a record would have the same values as another one in a
+//unique column or in the primary key.
+var ErrDuplicate error = errors.New("Error : Duplicate value")
+
+//ErrConstraint is the Kind of the DatabaseError returned when a record would break another constraint of the table :
+//a NOT NULL column, a foreign key or a check.
+var ErrConstraint error = errors.New("Error : Constraint failed")
+
+//DatabaseError is an error of the database driver recognized by the dialect. errors.Is tells its Kind :
+//
+//    _, _, err := users.AddNewRecord(object)
+//    if errors.Is(err, models.ErrDuplicate) {
+//        // the email is already taken
+//    }
+type DatabaseError struct {
+	Kind error
+	Err  error
+}
+
+//Error returns the message of the Kind followed by the one of the driver.
+func (e *DatabaseError) Error() (string) {
+	return e.Kind.Error() + " : " + e.Err.Error()
+}
+
+//Is tells whether the target is the Kind of the error.
+func (e *DatabaseError) Is(target error) (bool) {
+	return target == e.Kind
+}
+
+//Unwrap returns the error of the driver.
+func (e *DatabaseError) Unwrap() (error) {
+	return e.Err
+}
+
+//databaseError returns a DatabaseError of the kind for an error of the driver, or the error itself if the kind is nil.
+func databaseError(kind error, err error) (error) {
+	if (kind == nil) || (err == nil) {
+		return err
+	}
+	return &DatabaseError{Kind : kind, Err : err}
+}
+
+//translate maps an error of the driver with the dialect.
+func translate(err error) (error) {
+	if err == nil {
+		return err
+	}
+	return dialect.TranslateError(err)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+//QueryEvent is a statement run by the models, given to the QueryLogger once it is done.
+type QueryEvent struct {
+	//The statement, with the placeholders of the dialect.
+	Query        string
+	//The values bound to the placeholders, each replaced by "?" if the Database has RedactQueryArgs.
+	Args         []interface{}
+	//How long the statement took to run. For a query, until the rows were returned (not read).
+	Duration     time.Duration
+	//The number of rows changed by a statement or read from a query, -1 if the driver doesn't tell.
+	RowsAffected int64
+	Err          error
+}
+
+//QueryLogger receives the statements run by the models, with the context they ran with (see Model.WithContext). It
+//is set with SetQueryLogger :
+//
+//    type metrics struct{}
+//
+//    func (metrics) LogQuery(ctx context.Context, event models.QueryEvent) {
+//        queryDuration.Observe(event.Duration.Seconds())
+//    }
+//
+//    models.SetQueryLogger(metrics{})
+type QueryLogger interface {
+	LogQuery(ctx context.Context, event QueryEvent)
+}
+
+//Logger is the default QueryLogger. With Debug, it writes every statement to Out ; otherwise only those which failed
+//or took SlowQuery or more (if SlowQuery isn't 0). salt sets Debug from its configuration and SlowQuery from the
+//Database configuration.
+type Logger struct {
+	Out       io.Writer
+	Debug     bool
+	SlowQuery time.Duration
+}
+
+//LogQuery writes the statement with its duration, rows and error, eg.
+//
+//    [salt] 1.2ms 1 rows : UPDATE "Users" SET "Age"=? WHERE "ID" = ? [31 4]
+func (l *Logger) LogQuery(ctx context.Context, event QueryEvent) {
+	slow := (l.SlowQuery > 0) && (event.Duration >= l.SlowQuery)
+	if !l.Debug && !slow && (event.Err == nil) {
+		return
+	}
+	prefix := "[salt]"
+	if slow {
+		prefix = "[salt] slow query"
+	}
+	line := fmt.Sprintf("%s %s", prefix, event.Duration)
+	if event.RowsAffected >= 0 {
+		line += fmt.Sprintf(" %d rows", event.RowsAffected)
+	}
+	line += " : " + event.Query
+	if len(event.Args) > 0 {
+		line += fmt.Sprint(" ", event.Args)
+	}
+	if event.Err != nil {
+		line += " : " + event.Err.Error()
+	}
+	fmt.Fprintln(l.Out, line)
+}
+
+//Print writes a message of the models to Out whatever Debug, eg. the migrations being applied or the plan of a dry
+//run.
+func (l *Logger) Print(a ...interface{}) {
+	fmt.Fprintln(l.Out, append([]interface{}{"[salt]"}, a...)...)
+}
+
+//printer is a QueryLogger which takes the messages of the models too, like Logger.
+type printer interface {
+	Print(a ...interface{})
+}
+
+//LogMessage gives a message about the models to the QueryLogger if it has a Print method like Logger, otherwise to the
+//DefaultLogger.
+func LogMessage(a ...interface{}) {
+	if p, ok := queryLogger.(printer); ok {
+		p.Print(a...)
+		return
+	}
+	DefaultLogger.Print(a...)
+}
+
+//DefaultLogger is the QueryLogger of the models until another one is set.
+var DefaultLogger = &Logger{Out : os.Stdout}
+
+var queryLogger QueryLogger = DefaultLogger
+
+//Whether the values of the statements are left out of the QueryEvents (the RedactQueryArgs of the Database).
+var redactArgs bool
+
+//SetQueryLogger sets the QueryLogger receiving the statements run by the models. nil stops the logging.
+func SetQueryLogger(logger QueryLogger) {
+	queryLogger = logger
+}
+
+//SetDebug makes the DefaultLogger write every statement, or only the slow and failed ones.
+func SetDebug(debug bool) {
+	DefaultLogger.Debug = debug
+}
+
+type queryCounterKey struct{}
+
+//CountQueries returns a context counting the statements run with it, or with contexts derived from it (see
+//QueryCount). salt counts the queries of each request in Debug mode.
+func CountQueries(ctx context.Context) (context.Context) {
+	return context.WithValue(ctx, queryCounterKey{}, new(int64))
+}
+
+//QueryCount returns the number of statements run with a context made by CountQueries, 0 for other contexts.
+func QueryCount(ctx context.Context) (int) {
+	counter, ok := ctx.Value(queryCounterKey{}).(*int64)
+	if !ok {
+		return 0
+	}
+	return int(atomic.LoadInt64(counter))
+}
+
+//logQuery counts the statement in its context and gives it to the QueryLogger.
+func logQuery(ctx context.Context, query string, args []interface{}, duration time.Duration, rows int64, err error) {
+	if counter, ok := ctx.Value(queryCounterKey{}).(*int64); ok {
+		atomic.AddInt64(counter, 1)
+	}
+	if queryLogger == nil {
+		return
+	}
+	if redactArgs && (len(args) > 0) {
+		redacted := make([]interface{}, len(args))
+		for i := range redacted {
+			redacted[i] = "?"
+		}
+		args = redacted
+	}
+	queryLogger.LogQuery(ctx, QueryEvent{Query : query, Args : args, Duration : duration, RowsAffected : rows, Err : err})
+}
+
+//logExec logs a statement run with Exec, with the rows it changed.
+func logExec(ctx context.Context, query string, args []interface{}, start time.Time, result sql.Result, err error) {
+	duration := time.Since(start)
+	var rows int64 = -1
+	if err == nil {
+		if n, rowsErr := result.RowsAffected(); rowsErr == nil {
+			rows = n
+		}
+	}
+	logQuery(ctx, query, args, duration, rows, err)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	fast := QueryEvent{Query: `SELECT * FROM "users" WHERE "ID" = ?`, Args: []interface{}{4}, Duration: time.Millisecond, RowsAffected: 1}
+	slow := fast
+	slow.Duration = time.Second
+	failed := QueryEvent{Query: `DELETE FROM "users"`, Duration: time.Millisecond, RowsAffected: -1, Err: errors.New("locked")}
+	tests := []struct {
+		name   string
+		logger Logger
+		event  QueryEvent
+		want   string
+	}{
+		{"quiet", Logger{}, fast, ""},
+		{"debug", Logger{Debug: true}, fast, "[salt] 1ms 1 rows : SELECT * FROM \"users\" WHERE \"ID\" = ? [4]\n"},
+		{"slow", Logger{SlowQuery: 500 * time.Millisecond}, slow, "[salt] slow query 1s 1 rows : SELECT * FROM \"users\" WHERE \"ID\" = ? [4]\n"},
+		{"not slow", Logger{SlowQuery: 500 * time.Millisecond}, fast, ""},
+		{"failed", Logger{}, failed, "[salt] 1ms : DELETE FROM \"users\" : locked\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		test.logger.Out = &out
+		test.logger.LogQuery(context.Background(), test.event)
+		if out.String() != test.want {
+			t.Errorf("%s : LogQuery wrote %q, want %q", test.name, out.String(), test.want)
+		}
+	}
+}
+
+func TestLogMessage(t *testing.T) {
+	var out bytes.Buffer
+	previous := DefaultLogger.Out
+	DefaultLogger.Out = &out
+	t.Cleanup(func() {
+		DefaultLogger.Out = previous
+		SetQueryLogger(DefaultLogger)
+	})
+	//The messages are written whatever Debug.
+	LogMessage("Applying migration", "20161018120000_auto")
+	if want := "[salt] Applying migration 20161018120000_auto\n"; out.String() != want {
+		t.Errorf("LogMessage wrote %q, want %q", out.String(), want)
+	}
+	//A QueryLogger without a Print method leaves them to the DefaultLogger.
+	out.Reset()
+	SetQueryLogger(&events{})
+	LogMessage("Reverting migration", "20161018120000_auto")
+	if want := "[salt] Reverting migration 20161018120000_auto\n"; out.String() != want {
+		t.Errorf("LogMessage with another QueryLogger wrote %q, want %q", out.String(), want)
+	}
+	var own bytes.Buffer
+	SetQueryLogger(&Logger{Out: &own})
+	LogMessage("done")
+	if own.String() != "[salt] done\n" {
+		t.Errorf("LogMessage gave %q to the QueryLogger, want %q", own.String(), "[salt] done\n")
+	}
+}
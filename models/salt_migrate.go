@@ -0,0 +1,592 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Column describes a column of a table, either as declared by a model or as found in the database.
+//Type is spelled like the ColumnType of the dialect, so declared and live columns can be compared. Default is the SQL
+//of the default value, Check the SQL of the check constraint and References the foreign key. The databases rewrite
+//the SQL of defaults and checks (casts, quotes, parentheses), so they are compared with sameSQL.
+type Column struct {
+	Name          string
+	Type          string
+	NotNull       bool
+	Unique        bool
+	PrimaryKey    bool
+	AutoIncrement bool
+	Default       string `json:",omitempty"`
+	Check         string `json:",omitempty"`
+	References    *Reference `json:",omitempty"`
+}
+
+//Index is an index of a table on one or more columns. The indexes made by salt are named
+//<table>_<column>_..._idx, or _uniq for the unique ones, unless they are given a Name.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+//Expr is an SQL expression used as the Default of a Field, instead of a literal value :
+//
+//    "Created" : models.Field{Type : models.DateTime, Default : models.Expr("CURRENT_TIMESTAMP")}
+type Expr string
+
+//tableSchema is what the migrations know of a table.
+type tableSchema struct {
+	Columns []Column
+	Indexes []Index `json:",omitempty"`
+}
+
+//Columns returns the columns the model declares, the primary key first and then the others by name. The timestamp
+//columns and the columns referring to the BelongsTo model and to the models of the ForeignKey relations are included.
+func (model *Model) Columns() ([]Column) {
+	_, inline := dialect.AutoIncrement("")
+	var columns []Column
+	for name, field := range model.Fields {
+		column := Column{
+			Name:          name,
+			Type:          dialect.ColumnType(field),
+			NotNull:       field.NotNull,
+			Unique:        field.Unique,
+			PrimaryKey:    name == model.PrimaryKey,
+			AutoIncrement: field.AutoIncrement,
+			Default:       defaultSQL(field.Default),
+			Check:         field.Check,
+		}
+		//Dialects declaring the primary key inline (SQLite) can only auto increment the primary key.
+		if inline && !column.PrimaryKey {
+			column.AutoIncrement = false
+		}
+		columns = append(columns, column)
+	}
+	for name, field := range model.managedFields() {
+		if _, ok := model.Fields[name]; !ok {
+			columns = append(columns, Column{Name : name, Type : dialect.ColumnType(field)})
+		}
+	}
+	if (model.hasBelongsTo()) {
+		if val, ok := model.BelongsTo.Fields[model.BelongsTo.PrimaryKey]; ok {
+			columns = append(columns, Column{
+				Name:    model.BelongsTo.Name + "_" + model.BelongsTo.PrimaryKey,
+				Type:    dialect.ColumnType(val),
+				NotNull: true,
+			})
+		}
+	}
+	for _, name := range model.relationNames() {
+		rel := model.Relations[name]
+		if (rel.Kind != ForeignKey) || (rel.Model == nil) {
+			continue
+		}
+		column := Column{
+			Name:       model.relationColumn(name, rel),
+			Type:       dialect.ColumnType(referenceField(rel.Model)),
+			References: &Reference{Table : rel.Model.Name, Column : rel.Model.PrimaryKey, OnDelete : rel.OnDelete, OnUpdate : rel.OnUpdate},
+		}
+		declared := false
+		for i := range columns {
+			if columns[i].Name == column.Name {
+				columns[i].References, declared = column.References, true
+			}
+		}
+		if !declared {
+			columns = append(columns, column)
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].PrimaryKey != columns[j].PrimaryKey {
+			return columns[i].PrimaryKey
+		}
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+//allIndexes returns the indexes of the model : those of the fields with Index, the Indexes and the UniqueTogether
+//ones, sorted by name.
+func (model *Model) allIndexes() ([]Index) {
+	var indexes []Index
+	for name, field := range model.Fields {
+		if field.Index {
+			indexes = append(indexes, Index{Columns : []string{name}})
+		}
+	}
+	indexes = append(indexes, model.Indexes...)
+	for _, columns := range model.UniqueTogether {
+		indexes = append(indexes, Index{Columns : columns, Unique : true})
+	}
+	for i := range indexes {
+		if indexes[i].Name == "" {
+			indexes[i].Name = indexName(model.Name, indexes[i])
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	return indexes
+}
+
+//indexName returns the name salt gives to an index of the table.
+func indexName(table string, index Index) (string) {
+	suffix := "_idx"
+	if index.Unique {
+		suffix = "_uniq"
+	}
+	return table + "_" + strings.Join(index.Columns, "_") + suffix
+}
+
+//checkName returns the name of the check constraint of a column.
+func checkName(table string, column string) (string) {
+	return table + "_" + column + "_check"
+}
+
+//defaultSQL returns the SQL of the default value of a field : Expr values as they are, strings and times quoted,
+//numbers and booleans as literals. It is empty if there is no default.
+func defaultSQL(value interface{}) (string) {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case Expr:
+		return string(v)
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05") + "'"
+	}
+	return defaultSQL(fmt.Sprint(value))
+}
+
+//schema returns what the migrations know of the table of the model.
+func (model *Model) schema() (tableSchema) {
+	return tableSchema{model.Columns(), model.allIndexes()}
+}
+
+//columnDefinition returns the definition of the column used in CREATE TABLE and ALTER TABLE statements.
+func columnDefinition(d Dialect, table string, column Column) (string) {
+	columnType := column.Type
+	if column.AutoIncrement {
+		autoType, inline := d.AutoIncrement(columnType)
+		if !inline || column.PrimaryKey {
+			columnType = autoType
+		}
+	}
+	definition := d.Quote(column.Name) + " " + columnType
+	if column.Default != "" {
+		definition += " DEFAULT " + column.Default
+	}
+	if column.NotNull {
+		definition += " NOT NULL"
+	}
+	if column.Unique {
+		definition += " UNIQUE"
+	}
+	if column.Check != "" {
+		definition += " CONSTRAINT " + d.Quote(checkName(table, column.Name)) + " CHECK (" + column.Check + ")"
+	}
+	return definition
+}
+
+//createStatements returns the CREATE TABLE and CREATE INDEX statements of the model.
+func (model *Model) createStatements() ([]string) {
+	return createStatements(dialect, model.Name, model.schema())
+}
+
+//createStatements returns the CREATE TABLE and CREATE INDEX statements of a table.
+func createStatements(d Dialect, table string, schema tableSchema) ([]string) {
+	stmts := []string{createTable(d, table, schema.Columns)}
+	for _, index := range schema.Indexes {
+		stmts = append(stmts, createIndex(d, table, index))
+	}
+	return stmts
+}
+
+//createTable returns the CREATE TABLE statement of a table with the columns.
+func createTable(d Dialect, table string, columns []Column) (string) {
+	var definitions, primaryKey, foreignKeys []string
+	inlinePrimaryKey := false
+	for _, column := range columns {
+		if column.References != nil {
+			foreignKeys = append(foreignKeys, foreignKey(d, table, column))
+		}
+		definitions = append(definitions, columnDefinition(d, table, column))
+		if column.PrimaryKey {
+			primaryKey = append(primaryKey, column.Name)
+			if column.AutoIncrement {
+				_, inlinePrimaryKey = d.AutoIncrement(column.Type)
+			}
+		}
+	}
+	if ( len(primaryKey) > 0 ) && !inlinePrimaryKey {
+		definitions = append(definitions, "PRIMARY KEY(" + quoteAll(d, primaryKey) + ")")
+	}
+	definitions = append(definitions, foreignKeys...)
+	return "CREATE TABLE " + d.Quote(table) + " ( " + strings.Join(definitions, ",") + ")"
+}
+
+//createIndex returns the CREATE INDEX statement of an index of the table.
+func createIndex(d Dialect, table string, index Index) (string) {
+	create := "CREATE INDEX "
+	if index.Unique {
+		create = "CREATE UNIQUE INDEX "
+	}
+	return create + d.Quote(index.Name) + " ON " + d.Quote(table) + " (" + quoteAll(d, index.Columns) + ")"
+}
+
+//sameType tells whether two column types are the same, ignoring case and spaces.
+func sameType(a, b string) (bool) {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Replace(s, " ", "", -1))
+	}
+	return normalize(a) == normalize(b)
+}
+
+//sameColumn tells whether the live column matches the declared one. Primary key columns are always NOT NULL and
+//unique, whatever the database reports for them.
+func sameColumn(live, declared Column) (bool) {
+	notNull := func(c Column) bool { return c.NotNull || c.PrimaryKey }
+	unique := func(c Column) bool { return c.Unique && !c.PrimaryKey }
+	return sameType(live.Type, declared.Type) &&
+		notNull(live) == notNull(declared) &&
+		unique(live) == unique(declared) &&
+		live.AutoIncrement == declared.AutoIncrement &&
+		sameSQL(live.Default, declared.Default) &&
+		sameSQL(live.Check, declared.Check) &&
+		sameReference(live.References, declared.References)
+}
+
+//The casts PostgreSQL adds to the defaults and checks it returns, eg. 'salt'::character varying.
+var castPattern = regexp.MustCompile(`::(character varying|timestamp without time zone|timestamp with time zone|` +
+	`time without time zone|double precision|[a-z_]+)(\[\])?(\([0-9, ]*\))?`)
+
+//normalizeSQL returns the SQL of a default or check without casts, and, out of the string literals, without spaces,
+//parentheses and identifier quotes and in lower case.
+func normalizeSQL(s string) (string) {
+	parts := strings.Split(castPattern.ReplaceAllString(s, ""), "'")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(" \t\n()\"`", r) {
+				return -1
+			}
+			return r
+		}, strings.ToLower(parts[i]))
+	}
+	return strings.Join(parts, "'")
+}
+
+//sameSQL tells whether two defaults or checks are the same, as written in a model and as the database returns them.
+//Numbers are compared by value, the databases write them with the scale of the column.
+func sameSQL(a, b string) (bool) {
+	a, b = normalizeSQL(a), normalizeSQL(b)
+	if a == b {
+		return true
+	}
+	x, errA := strconv.ParseFloat(strings.Trim(a, "'"), 64)
+	y, errB := strconv.ParseFloat(strings.Trim(b, "'"), 64)
+	return (errA == nil) && (errB == nil) && (x == y)
+}
+
+//sameIndex tells whether two indexes are the same.
+func sameIndex(a, b Index) (bool) {
+	return (a.Name == b.Name) && (a.Unique == b.Unique) && (strings.Join(a.Columns, ",") == strings.Join(b.Columns, ","))
+}
+
+//Diff compares the model with its table in the database and returns the statements bringing the table in line with
+//the model : a CREATE TABLE if the table doesn't exist yet, otherwise the ALTER TABLE statements adding the new
+//columns, dropping the removed ones, changing the ones whose type, nullability, uniqueness, auto increment, default,
+//check or foreign key changed and moving the primary key, and the statements creating and dropping the indexes. Only
+//the indexes named like salt names them (or named in the model) are dropped. The join tables of the ManyToMany relations which don't exist yet
+//are created. It returns nothing when the table is up to date.
+//
+//SQLite can't alter a column or move the primary key, so the table is rebuilt instead (see ErrRebuildTable).
+func (model *Model) Diff() ([]string, error) {
+	return model.diff(true)
+}
+
+//diff returns the statements of Diff, without those dropping the removed columns unless dropColumns.
+func (model *Model) diff(dropColumns bool) ([]string, error) {
+	if !model.IsMigrated() {
+		return append(model.createStatements(), model.joinStatements()...), nil
+	}
+	e, err := model.executor()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := dialect.Columns(e, model.Name)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := dialect.Indexes(e, model.Name)
+	if err != nil {
+		return nil, err
+	}
+	declared := model.schema()
+	live := tableSchema{Columns : columns}
+	for _, index := range indexes {
+		if managedIndex(model.Name, index.Name, declared.Indexes) {
+			live.Indexes = append(live.Indexes, index)
+		}
+	}
+	stmts, err := diffTable(dialect, model.Name, live, declared, dropColumns)
+	if err != nil {
+		return nil, err
+	}
+	return append(stmts, model.joinStatements()...), nil
+}
+
+//managedIndex tells whether the index of the table is one of the model's or named like salt names them.
+func managedIndex(table string, name string, declared []Index) (bool) {
+	for _, index := range declared {
+		if index.Name == name {
+			return true
+		}
+	}
+	return strings.HasPrefix(name, table + "_") && (strings.HasSuffix(name, "_idx") || strings.HasSuffix(name, "_uniq"))
+}
+
+//diffTable returns the statements changing the table from the live schema to the declared one. The live columns which
+//are not declared are only dropped if dropColumns.
+func diffTable(d Dialect, table string, live tableSchema, declared tableSchema, dropColumns bool) ([]string, error) {
+	var stmts, created []string
+	for _, index := range live.Indexes {
+		if !containsIndex(declared.Indexes, index) {
+			stmts = append(stmts, d.DropIndex(table, index.Name))
+		}
+	}
+	for _, index := range declared.Indexes {
+		if !containsIndex(live.Indexes, index) {
+			created = append(created, createIndex(d, table, index))
+		}
+	}
+	altered, err := diffColumns(d, table, live.Columns, declared.Columns, dropColumns)
+	if errors.Is(err, ErrRebuildTable) {
+		return rebuildTable(d, table, live, declared, dropColumns), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stmts = append(stmts, altered...)
+	return append(stmts, created...), nil
+}
+
+//ErrRebuildTable is returned by the AlterColumn or SetPrimaryKey of a dialect which can't change the table in place,
+//like SQLite. Diff then rebuilds the table : it is made again under another name, the rows are copied into it, the old
+//table is dropped and the new one renamed. The indexes which aren't in the model are lost.
+var ErrRebuildTable = errors.New("Error : The table has to be rebuilt")
+
+//rebuildTable returns the statements rebuilding the table with the declared schema (see ErrRebuildTable). The live
+//columns which are not declared are kept unless dropColumns, and the values of all the columns kept are copied.
+func rebuildTable(d Dialect, table string, live tableSchema, declared tableSchema, dropColumns bool) ([]string) {
+	columns := append([]Column{}, declared.Columns...)
+	declaredByName := make(map[string]bool)
+	for _, column := range declared.Columns {
+		declaredByName[column.Name] = true
+	}
+	var copied []string
+	for _, column := range live.Columns {
+		if !declaredByName[column.Name] {
+			if dropColumns {
+				continue
+			}
+			column.PrimaryKey, column.AutoIncrement = false, false
+			columns = append(columns, column)
+		}
+		copied = append(copied, column.Name)
+	}
+	rebuilt := "salt_rebuild_" + table
+	//The constraints are named after the table, not after the name it is made under.
+	definition := strings.TrimPrefix(createTable(d, table, columns), "CREATE TABLE " + d.Quote(table))
+	stmts := []string{
+		"CREATE TABLE " + d.Quote(rebuilt) + definition,
+		"INSERT INTO " + d.Quote(rebuilt) + " (" + quoteAll(d, copied) + ") SELECT " + quoteAll(d, copied) + " FROM " + d.Quote(table),
+		"DROP TABLE " + d.Quote(table),
+		"ALTER TABLE " + d.Quote(rebuilt) + " RENAME TO " + d.Quote(table),
+	}
+	for _, index := range declared.Indexes {
+		stmts = append(stmts, createIndex(d, table, index))
+	}
+	return stmts
+}
+
+//schemaConnection runs fn on a connection of the pool kept for it. The foreign keys of SQLite are turned off meanwhile
+//(which SQLite only allows outside of a transaction), as dropping a table being rebuilt would otherwise delete the rows
+//referring to it or fail. fn should check them with checkForeignKeys before it is done.
+func schemaConnection(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, sqlite := dialect.(sqliteDialect); !sqlite {
+		return fn(conn)
+	}
+	_, err = conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		//The connection goes back to the pool, it must enforce the foreign keys again.
+		_, onErr := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+		if (err == nil) {
+			err = onErr
+		}
+	}()
+	return fn(conn)
+}
+
+//checkForeignKeys returns an error if rows of the SQLite database refer to rows which don't exist, as the schema
+//changes run by schemaConnection aren't checked while they run. query is the QueryContext of the connection or
+//transaction they run on.
+func checkForeignKeys(ctx context.Context, query func(context.Context, string, ...interface{}) (*sql.Rows, error)) (error) {
+	if _, sqlite := dialect.(sqliteDialect); !sqlite {
+		return nil
+	}
+	rows, err := query(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowid, id sql.NullInt64
+		err = rows.Scan(&table, &rowid, &parent, &id)
+		if err != nil {
+			return err
+		}
+		return databaseError(ErrConstraint, errors.New("FOREIGN KEY constraint failed: a row of " + table + " refers to a missing row of " + parent))
+	}
+	return rows.Err()
+}
+
+//containsIndex tells whether the index is in the indexes.
+func containsIndex(indexes []Index, index Index) (bool) {
+	for _, other := range indexes {
+		if sameIndex(other, index) {
+			return true
+		}
+	}
+	return false
+}
+
+//diffColumns returns the ALTER TABLE statements changing the table from the live columns to the declared ones.
+func diffColumns(d Dialect, table string, live []Column, declared []Column, dropColumns bool) ([]string, error) {
+	liveByName := make(map[string]Column)
+	var livePrimaryKey, declaredPrimaryKey []string
+	for _, column := range live {
+		liveByName[column.Name] = column
+		if column.PrimaryKey {
+			livePrimaryKey = append(livePrimaryKey, column.Name)
+		}
+	}
+	var stmts []string
+	declaredByName := make(map[string]bool)
+	for _, column := range declared {
+		declaredByName[column.Name] = true
+		if column.PrimaryKey {
+			declaredPrimaryKey = append(declaredPrimaryKey, column.Name)
+		}
+		current, ok := liveByName[column.Name]
+		if !ok {
+			added := column
+			added.PrimaryKey = false
+			stmts = append(stmts, d.AddColumn(table, added))
+			continue
+		}
+		if !sameColumn(current, column) {
+			altered, err := d.AlterColumn(table, current, column)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, altered...)
+		}
+	}
+	//The primary key is moved before the old columns are dropped, as a column can't be dropped while it is the primary key.
+	if strings.Join(livePrimaryKey, ",") != strings.Join(declaredPrimaryKey, ",") {
+		moved, err := d.SetPrimaryKey(table, livePrimaryKey, declaredPrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, moved...)
+	}
+	for _, column := range live {
+		if dropColumns && !declaredByName[column.Name] {
+			stmts = append(stmts, d.DropColumn(table, column.Name))
+		}
+	}
+	return stmts, nil
+}
+
+//Migrate brings the table of the model in line with the model (see Diff) and returns the statements of the plan.
+//With dryRun the plan is only logged (see LogMessage), nothing is changed in the database. The columns which are no
+//longer in the model are only dropped (with their data) if dropColumns.
+//
+//The statements are run one by one, so if one of them fails the ones before it stay applied. A model bound to a
+//transaction can't rebuild SQLite tables other tables refer to (see schemaConnection).
+func (model *Model) Migrate(dryRun bool, dropColumns bool) ([]string, error) {
+	stmts, err := model.diff(dropColumns)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		for _, stmt := range stmts {
+			LogMessage(stmt + ";")
+		}
+		return stmts, nil
+	}
+	if (model.tx != nil) {
+		for _, stmt := range stmts {
+			_, err = model.execSchema(stmt)
+			if err != nil {
+				return stmts, err
+			}
+		}
+		return stmts, nil
+	}
+	ctx := model.baseContext()
+	return stmts, schemaConnection(ctx, func(conn *sql.Conn) error {
+		for _, stmt := range stmts {
+			start := time.Now()
+			result, err := conn.ExecContext(ctx, stmt)
+			logExec(ctx, stmt, nil, start, result, err)
+			if err != nil {
+				return err
+			}
+		}
+		return checkForeignKeys(ctx, conn.QueryContext)
+	})
+}
+
+//Migrate migrates all the models (see Model.Migrate) and returns the statements of the plan.
+func (models Models) Migrate(dryRun bool, dropColumns bool) ([]string, error) {
+	var plan []string
+	for index := range models {
+		stmts, err := models[index].Migrate(dryRun, dropColumns)
+		plan = append(plan, stmts...)
+		if err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
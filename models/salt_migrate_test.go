@@ -0,0 +1,246 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefaultSQL(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, ""},
+		{Expr("CURRENT_TIMESTAMP"), "CURRENT_TIMESTAMP"},
+		{"draft", "'draft'"},
+		{"it's", "'it''s'"},
+		{true, "TRUE"},
+		{false, "FALSE"},
+		{0, "0"},
+		{int64(-7), "-7"},
+		{1.5, "1.5"},
+		{time.Date(2016, 10, 18, 12, 30, 0, 0, time.UTC), "'2016-10-18 12:30:00'"},
+		{uint8(3), "'3'"},
+	}
+	for _, test := range tests {
+		if got := defaultSQL(test.value); got != test.want {
+			t.Errorf("defaultSQL(%#v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestSameSQL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"'draft'::character varying", "'draft'", true},
+		{"'2016-10-18 12:30:00'::timestamp without time zone", "'2016-10-18 12:30:00'", true},
+		{"'-1'::integer", "-1", true},
+		{"1.50", "1.5", true},
+		{`(("Age" >= 0))`, "Age >= 0", true},
+		{"(`Age` >= 0)", "Age >= 0", true},
+		{"current_timestamp()", "CURRENT_TIMESTAMP", true},
+		{"", "", true},
+		{"'Draft'", "'draft'", false},
+		{"Age > 0", "Age >= 0", false},
+		{"0", "", false},
+	}
+	for _, test := range tests {
+		if got := sameSQL(test.a, test.b); got != test.same {
+			t.Errorf("sameSQL(%q, %q) = %v, want %v", test.a, test.b, got, test.same)
+		}
+	}
+}
+
+func TestAlterColumn(t *testing.T) {
+	name := Column{Name: "Name", Type: "varchar(255)"}
+	with := func(column Column, change func(c *Column)) Column {
+		change(&column)
+		return column
+	}
+	tests := []struct {
+		d        Dialect
+		live     Column
+		declared Column
+		want     []string
+	}{
+		{postgresDialect{"postgres"}, name, with(name, func(c *Column) { c.Type = "TEXT" }),
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" TYPE TEXT USING "Name"::TEXT`}},
+		{postgresDialect{"postgres"}, name, with(name, func(c *Column) { c.NotNull, c.Unique = true, true }),
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" SET NOT NULL`, `ALTER TABLE "t" ADD CONSTRAINT "t_Name_key" UNIQUE ("Name")`}},
+		{postgresDialect{"postgres"}, with(name, func(c *Column) { c.NotNull, c.Unique = true, true }), name,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" DROP NOT NULL`, `ALTER TABLE "t" DROP CONSTRAINT "t_Name_key"`}},
+		{mysqlDialect{}, name, with(name, func(c *Column) { c.NotNull, c.Unique = true, true }),
+			[]string{"ALTER TABLE `t` MODIFY COLUMN `Name` varchar(255) NOT NULL", "ALTER TABLE `t` ADD UNIQUE (`Name`)"}},
+		{mysqlDialect{}, with(name, func(c *Column) { c.Unique = true }), name,
+			[]string{"ALTER TABLE `t` MODIFY COLUMN `Name` varchar(255)", "ALTER TABLE `t` DROP INDEX `Name`"}},
+	}
+	for _, test := range tests {
+		got, err := test.d.AlterColumn("t", test.live, test.declared)
+		if (err != nil) || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%T.AlterColumn(%+v, %+v) =\n%q, %v\nwant\n%q", test.d, test.live, test.declared, got, err, test.want)
+		}
+	}
+	if _, err := (postgresDialect{"postgres"}).AlterColumn("t", name, with(name, func(c *Column) { c.AutoIncrement = true })); err == nil {
+		t.Error("postgres : changing the auto increment : no error")
+	}
+}
+
+func TestSetPrimaryKey(t *testing.T) {
+	got, _ := (postgresDialect{"postgres"}).SetPrimaryKey("t", []string{"ID"}, []string{"Owner", "Slug"})
+	want := []string{`ALTER TABLE "t" DROP CONSTRAINT "t_pkey"`, `ALTER TABLE "t" ADD PRIMARY KEY ("Owner","Slug")`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("postgres : SetPrimaryKey = %q, want %q", got, want)
+	}
+	got, _ = (mysqlDialect{}).SetPrimaryKey("t", nil, []string{"ID"})
+	want = []string{"ALTER TABLE `t` ADD PRIMARY KEY (`ID`)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mysql : SetPrimaryKey = %q, want %q", got, want)
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	d := postgresDialect{"postgres"}
+	id := Column{Name: "ID", Type: "INTEGER", NotNull: true, PrimaryKey: true, AutoIncrement: true}
+	name := Column{Name: "Name", Type: "varchar(255)"}
+	age := Column{Name: "Age", Type: "INTEGER", Default: "0", Check: "Age >= 0"}
+	author := Column{Name: "Author_ID", Type: "INTEGER", References: &Reference{Table: "authors", Column: "ID"}}
+	with := func(column Column, change func(c *Column)) Column {
+		change(&column)
+		return column
+	}
+	tests := []struct {
+		name        string
+		live        []Column
+		declared    []Column
+		dropColumns bool
+		want        []string
+	}{
+		{"same", []Column{id, name, age, author}, []Column{id, name, age, author}, true, nil},
+		{"added", []Column{id}, []Column{id, name}, true, []string{`ALTER TABLE "t" ADD COLUMN "Name" varchar(255)`}},
+		{"dropped", []Column{id, name}, []Column{id}, true, []string{`ALTER TABLE "t" DROP COLUMN "Name"`}},
+		{"kept", []Column{id, name}, []Column{id}, false, nil},
+		{"not null", []Column{id, name}, []Column{id, with(name, func(c *Column) { c.NotNull = true })}, true,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" SET NOT NULL`}},
+		{"type", []Column{id, name}, []Column{id, with(name, func(c *Column) { c.Type = "TEXT" })}, true,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" TYPE TEXT USING "Name"::TEXT`}},
+		//The defaults and checks are compared as the database returns them.
+		{"read back", []Column{id, with(age, func(c *Column) { c.Default, c.Check = "'0'::integer", `(("Age" >= 0))` })},
+			[]Column{id, age}, true, nil},
+		{"default", []Column{id, age}, []Column{id, with(age, func(c *Column) { c.Default = "5" })}, true,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Age" SET DEFAULT 5`}},
+		{"no default", []Column{id, age}, []Column{id, with(age, func(c *Column) { c.Default = "" })}, true,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Age" DROP DEFAULT`}},
+		{"check", []Column{id, age}, []Column{id, with(age, func(c *Column) { c.Check = "Age > 0" })}, true,
+			[]string{`ALTER TABLE "t" DROP CONSTRAINT "t_Age_check"`, `ALTER TABLE "t" ADD CONSTRAINT "t_Age_check" CHECK (Age > 0)`}},
+		{"no action", []Column{id, with(author, func(c *Column) {
+			c.References = &Reference{Table: "authors", Column: "ID", OnDelete: NoAction, OnUpdate: NoAction}
+		})}, []Column{id, author}, true, nil},
+		{"on delete", []Column{id, author}, []Column{id, with(author, func(c *Column) {
+			c.References = &Reference{Table: "authors", Column: "ID", OnDelete: Cascade}
+		})}, true, []string{`ALTER TABLE "t" DROP CONSTRAINT "t_Author_ID_fkey"`,
+			`ALTER TABLE "t" ADD CONSTRAINT "t_Author_ID_fkey" FOREIGN KEY ("Author_ID") REFERENCES "authors" ("ID") ON DELETE CASCADE`}},
+		//The primary key is moved before the old one is dropped.
+		{"primary key", []Column{id, name}, []Column{with(name, func(c *Column) { c.PrimaryKey, c.NotNull = true, true })}, true,
+			[]string{`ALTER TABLE "t" ALTER COLUMN "Name" SET NOT NULL`, `ALTER TABLE "t" DROP CONSTRAINT "t_pkey"`,
+				`ALTER TABLE "t" ADD PRIMARY KEY ("Name")`, `ALTER TABLE "t" DROP COLUMN "ID"`}},
+	}
+	for _, test := range tests {
+		got, err := diffColumns(d, "t", test.live, test.declared, test.dropColumns)
+		if err != nil {
+			t.Errorf("%s : %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s : diffColumns =\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+	//SQLite can't alter columns, but can add them.
+	sqlite := sqliteDialect{"sqlite"}
+	if _, err := diffColumns(sqlite, "t", []Column{id, age}, []Column{id, with(age, func(c *Column) { c.Default = "5" })}, true); err == nil {
+		t.Error("sqlite : altering a column : no error")
+	}
+	got, err := diffColumns(sqlite, "t", []Column{id}, []Column{id, age}, true)
+	want := []string{`ALTER TABLE "t" ADD COLUMN "Age" INTEGER DEFAULT 0 CONSTRAINT "t_Age_check" CHECK (Age >= 0)`}
+	if (err != nil) || !reflect.DeepEqual(got, want) {
+		t.Errorf("sqlite : diffColumns = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestDiffTable(t *testing.T) {
+	d := postgresDialect{"postgres"}
+	id := Column{Name: "ID", Type: "INTEGER", NotNull: true, PrimaryKey: true}
+	name := Column{Name: "Name", Type: "varchar(255)"}
+	byName := Index{Name: "t_Name_idx", Columns: []string{"Name"}}
+	uniqueName := Index{Name: "t_Name_uniq", Columns: []string{"Name"}, Unique: true}
+	tests := []struct {
+		name     string
+		live     tableSchema
+		declared tableSchema
+		want     []string
+	}{
+		{"same", tableSchema{[]Column{id, name}, []Index{byName}}, tableSchema{[]Column{id, name}, []Index{byName}}, nil},
+		{"index added", tableSchema{[]Column{id, name}, nil}, tableSchema{[]Column{id, name}, []Index{byName}},
+			[]string{`CREATE INDEX "t_Name_idx" ON "t" ("Name")`}},
+		//Indexes are dropped first and created last, after the columns they are on are added.
+		{"index changed", tableSchema{[]Column{id}, []Index{byName}}, tableSchema{[]Column{id, name}, []Index{uniqueName}},
+			[]string{`DROP INDEX "t_Name_idx"`, `ALTER TABLE "t" ADD COLUMN "Name" varchar(255)`,
+				`CREATE UNIQUE INDEX "t_Name_uniq" ON "t" ("Name")`}},
+	}
+	for _, test := range tests {
+		got, err := diffTable(d, "t", test.live, test.declared, true)
+		if err != nil {
+			t.Errorf("%s : %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s : diffTable =\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRebuildTable(t *testing.T) {
+	d := sqliteDialect{"sqlite"}
+	id := Column{Name: "ID", Type: "INTEGER", NotNull: true, PrimaryKey: true, AutoIncrement: true}
+	name := Column{Name: "Name", Type: "VARCHAR(255)"}
+	old := Column{Name: "Old", Type: "TEXT"}
+	notNull := name
+	notNull.NotNull = true
+	byName := Index{Name: "t_Name_idx", Columns: []string{"Name"}}
+	live := tableSchema{[]Column{id, name, old}, []Index{byName}}
+	declared := tableSchema{[]Column{id, notNull}, []Index{byName}}
+	//The column which is no longer declared is kept, with its values, unless dropColumns.
+	want := []string{
+		`CREATE TABLE "salt_rebuild_t" ( "ID" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,"Name" VARCHAR(255) NOT NULL,"Old" TEXT)`,
+		`INSERT INTO "salt_rebuild_t" ("ID","Name","Old") SELECT "ID","Name","Old" FROM "t"`,
+		`DROP TABLE "t"`,
+		`ALTER TABLE "salt_rebuild_t" RENAME TO "t"`,
+		`CREATE INDEX "t_Name_idx" ON "t" ("Name")`,
+	}
+	got, err := diffTable(d, "t", live, declared, false)
+	if (err != nil) || !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTable =\n%q, %v\nwant\n%q", got, err, want)
+	}
+	want[0] = `CREATE TABLE "salt_rebuild_t" ( "ID" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,"Name" VARCHAR(255) NOT NULL)`
+	want[1] = `INSERT INTO "salt_rebuild_t" ("ID","Name") SELECT "ID","Name" FROM "t"`
+	got, err = diffTable(d, "t", live, declared, true)
+	if (err != nil) || !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTable with dropColumns =\n%q, %v\nwant\n%q", got, err, want)
+	}
+	//Moving the primary key rebuilds the table too.
+	plain, key := id, notNull
+	plain.PrimaryKey, plain.AutoIncrement = false, false
+	key.PrimaryKey = true
+	want = []string{
+		`CREATE TABLE "salt_rebuild_t" ( "ID" INTEGER NOT NULL,"Name" VARCHAR(255) NOT NULL,PRIMARY KEY("Name"))`,
+		`INSERT INTO "salt_rebuild_t" ("ID","Name") SELECT "ID","Name" FROM "t"`,
+		`DROP TABLE "t"`,
+		`ALTER TABLE "salt_rebuild_t" RENAME TO "t"`,
+	}
+	got, err = diffTable(d, "t", tableSchema{[]Column{id, notNull}, nil}, tableSchema{[]Column{plain, key}, nil}, true)
+	if (err != nil) || !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTable moving the primary key =\n%q, %v\nwant\n%q", got, err, want)
+	}
+}
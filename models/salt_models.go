@@ -1,10 +1,14 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -20,20 +24,80 @@ type Object struct {
 }
 
 //Field struct for conatining the column information in models
+//MaxLength is the length of a CharField column (255 if it is 0). Precision (10 if it is 0) and Scale are the number
+//of digits of a Decimal column and the number of them after the decimal point.
+//Default is the default value of the column, a literal (string, number, bool, time.Time) or an Expr like
+//Expr("CURRENT_TIMESTAMP"). Index adds an index on the column and Check a check constraint, eg. "Age >= 0".
+//Validators check the values written to the field by AddNewRecord and UpdateRecord (see Validator).
 type Field struct {
 	Type            Type
 	AutoIncrement   bool
 	NotNull         bool
 	Unique          bool
+	MaxLength       int
+	Precision       int
+	Scale           int
+	Default         interface{}
+	Index           bool
+	Check           string
+	Validators      []Validator
+}
+
+//DefaultMaxLength is the length of the CharField columns without a MaxLength.
+const DefaultMaxLength = 255
+
+//DefaultPrecision is the number of digits of the Decimal columns without a Precision.
+const DefaultPrecision = 10
+
+//precision returns the number of digits of the Decimal column of the field.
+func (field Field) precision() (int) {
+	if (field.Precision > 0) {
+		return field.Precision
+	}
+	return DefaultPrecision
+}
+
+//length returns the length of the CharField column of the field.
+func (field Field) length() (int) {
+	if (field.MaxLength > 0) {
+		return field.MaxLength
+	}
+	return DefaultMaxLength
 }
 
 //Model struct for the database table information
+//Indexes are the indexes on more than one column (or with a name) and UniqueTogether the sets of columns whose values
+//should be unique together, eg. [][]string{{"Owner", "Slug"}}.
+//Relations are the relations of the model with other models, by name (see Relation). The column BelongsTo adds has
+//no FOREIGN KEY constraint, a ForeignKey relation should be used instead.
+//The hooks are called by AddNewRecord (BeforeCreate, AfterCreate) and UpdateRecord (BeforeUpdate, AfterUpdate) with
+//the object passed to them, the Before hooks before the values are validated so they can change them, and by
+//DeleteRecord (BeforeDelete, AfterDelete) with each record deleted, which are only read if the model has those hooks.
+//With Timestamps the created_at and updated_at columns are set by AddNewRecord and UpdateRecord. With SoftDelete,
+//DeleteRecord sets the deleted_at column instead of deleting the records, and GetRecord, GetAll and the queries leave
+//out the soft deleted ones (see Query.WithDeleted).
 type Model struct {
 	Name             string
 	Fields           Fields
 	Objects          Objects
 	PrimaryKey       string
 	BelongsTo        *Model
+	Indexes          []Index
+	UniqueTogether   [][]string
+	Relations        map[string]Relation
+	Timestamps       bool
+	SoftDelete       bool
+	BeforeCreate     Hook
+	AfterCreate      Hook
+	BeforeUpdate     Hook
+	AfterUpdate      Hook
+	BeforeDelete     Hook
+	AfterDelete      Hook
+
+	//The transaction the model is bound to (see Tx.Model), nil for the connection pool.
+	tx               *Tx
+	//The context the model is bound to (see WithContext), nil for the one of the transaction or none.
+	ctx              context.Context
 }
 
 //Models type : array of Model struct
@@ -42,24 +106,68 @@ type Models []Model
 //Type : new type for models field type
 type Type string
 
-//Database type : struct to contain the database information
+//Database type : struct to contain the database information.
+//Driver selects the database (mysql, postgres or sqlite3, see Dialect), Host and Port default to the local server and
+//a DSN, if given, is passed to the driver as such instead of the one formed from the other fields (a MySQL DSN should
+//have clientFoundRows=true, see UpdateRecord).
+//SSLMode is the sslmode of PostgreSQL (disable, require, verify-full ...), "disable" by default for a local server
+//and the default of the driver otherwise.
+//MaxOpenConns and MaxIdleConns limit the open and idle connections of the pool (0 keeps the database/sql defaults)
+//and ConnMaxLifetime (eg. "5m") is how long a connection can be reused.
+//QueryTimeout (eg. "10s") is how long the queries of the models can run, for those without an earlier deadline in their
+//context (see Model.WithContext). The statements changing the tables (migrations) have no timeout.
+//SlowQuery (eg. "200ms") is the duration from which the DefaultLogger logs a statement even without Debug, and with
+//RedactQueryArgs the values of the statements are left out of the logs (see QueryLogger).
 type Database struct {
-	Username string `json:"Username"`
-	Password string `json:"Password"`
-	Database string `json:"Database"`
+	Driver          string `json:"Driver"`
+	Host            string `json:"Host"`
+	Port            string `json:"Port"`
+	DSN             string `json:"DSN"`
+	SSLMode         string `json:"SSLMode"`
+	Username        string `json:"Username"`
+	Password        string `json:"Password"`
+	Database        string `json:"Database"`
+	MaxOpenConns    int    `json:"MaxOpenConns"`
+	MaxIdleConns    int    `json:"MaxIdleConns"`
+	ConnMaxLifetime string `json:"ConnMaxLifetime"`
+	QueryTimeout    string `json:"QueryTimeout"`
+	SlowQuery       string `json:"SlowQuery"`
+	RedactQueryArgs bool   `json:"RedactQueryArgs"`
 }
 
+//The field types. Their values are read from and written to the database as :
+//
+//    CharField, TextField  -  string
+//    Integer               -  int
+//    BigInteger            -  int64
+//    Float                 -  float64
+//    Boolean               -  bool
+//    DateTime, Date, Time  -  time.Time (Date and Time values only keep the date or the time of day)
+//    Decimal               -  string, to keep every digit (float64 and int values can be written too)
+//    JSON                  -  json.RawMessage (other values are written encoded with encoding/json)
+//    Blob                  -  []byte
+//    UUID                  -  string, like "5f0c2a8e-0c8e-4b8e-9c5f-2c8e0c8e4b8e"
 const(
 	CharField     Type = "string"
 	TextField     Type = "text"
 	Integer       Type = "int"
 	Float         Type = "float"
 	Boolean       Type = "bool"
+	DateTime      Type = "datetime"
+	Date          Type = "date"
+	Time          Type = "time"
+	Decimal       Type = "decimal"
+	BigInteger    Type = "bigint"
+	JSON          Type = "json"
+	Blob          Type = "blob"
+	UUID          Type = "uuid"
 )
 var errMigrated error = errors.New("Error : There is already a table name with the same name as the model")
+var errNotConfigured error = errors.New("Error : The database is not configured")
 
 var modelstore Models
 var database   Database
+var db         *sql.DB
 
 func (models *Models) Register() (error) {
 	for _,val := range *models {
@@ -88,10 +196,20 @@ func (model *Model) Register() (error) {
 		}
 	}
 	if _,ok := model.Fields[model.PrimaryKey]; ( !ok ) {
-		fmt.Println(ok,model.PrimaryKey,model.Fields[model.PrimaryKey])
 		return errors.New("Error : Specified Primary key is not defined in the field list")
 	}
-	err := model.Check()
+	for _, index := range model.allIndexes() {
+		for _, column := range index.Columns {
+			if _, ok := model.field(column); !ok {
+				return errors.New("Error : The index " + index.Name + " is on " + column + ", which is not in the field list")
+			}
+		}
+	}
+	err := model.checkRelations()
+	if err != nil {
+		return err
+	}
+	err = model.Check()
 	if err != nil {
 		return err
 	}
@@ -100,18 +218,12 @@ func (model *Model) Register() (error) {
 		return err
 	}
 	modelstore = append(modelstore,*model)
-	println("Registered model : " + model.Name)
 	return nil
 }
 
 //
 func (model *Model) Check () (error) {
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-	rows, err := db.Query("SHOW TABLES")
+	rows, err := model.query(dialect.TablesQuery())
 	if err != nil {
 		return err
 	}
@@ -131,79 +243,184 @@ func (model *Model) Check () (error) {
 
 //SetDatabaseConfig : Set Database configuration from the Parent module "salt"
 //This step is done when the app.Configure is called.
+//It opens the connection pool shared by all the models and pings the database, so wrong credentials are reported
+//here rather than at the first query. A pool opened by an earlier call is closed.
 func SetDatabaseConfig(datab Database) (error) {
-	db, err := sql.Open("mysql", datab.Username+":"+datab.Password+"@/"+datab.Database)
-	defer db.Close()
-	if (err == nil) {
-		database = datab
-		fmt.Println("Able to open Connection to database")
+	newdialect, err := getDialect(datab)
+	if err != nil {
+		return err
+	}
+	var timeout time.Duration
+	if (datab.QueryTimeout != "") {
+		timeout, err = time.ParseDuration(datab.QueryTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	var slow time.Duration
+	if (datab.SlowQuery != "") {
+		slow, err = time.ParseDuration(datab.SlowQuery)
+		if err != nil {
+			return err
+		}
 	}
+	dsn := datab.DSN
+	if (dsn == "") {
+		dsn = newdialect.DSN(datab)
+	}
+	newdb, err := sql.Open(newdialect.DriverName(), dsn)
+	if err != nil {
+		return err
+	}
+	newdb.SetMaxOpenConns(datab.MaxOpenConns)
+	if (datab.MaxIdleConns != 0) {
+		newdb.SetMaxIdleConns(datab.MaxIdleConns)
+	}
+	if (datab.ConnMaxLifetime != "") {
+		lifetime, err := time.ParseDuration(datab.ConnMaxLifetime)
+		if err != nil {
+			newdb.Close()
+			return err
+		}
+		newdb.SetConnMaxLifetime(lifetime)
+	}
+	err = newdb.Ping()
+	if err != nil {
+		newdb.Close()
+		return err
+	}
+	if (db != nil) {
+		db.Close()
+	}
+	db = newdb
+	dialect = newdialect
+	queryTimeout = timeout
+	DefaultLogger.SlowQuery = slow
+	redactArgs = datab.RedactQueryArgs
+	database = datab
+	return nil
+}
+
+//DB returns the connection pool used by the models, for queries the models package can't do.
+//It is nil until SetDatabaseConfig succeeds.
+func DB() (*sql.DB) {
+	return db
+}
+
+//Close closes the connection pool of the models. It is meant to be called when the web-app shuts down, for example
+//from an App.OnShutdown hook.
+func Close() (error) {
+	if (db == nil) {
+		return nil
+	}
+	err := db.Close()
+	db = nil
 	return err
 }
 
-//AddToDatabase : Create a database for the corresponding Model
-func (model *Model) AddToDataBase() (error) {
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
+//connection returns the connection pool or an error if the database isn't configured yet.
+func connection() (*sql.DB, error) {
+	if (db == nil) {
+		return nil, errNotConfigured
+	}
+	return db, nil
+}
+
+//runQuery runs a query written with ? placeholders on the connection pool.
+func runQuery(query string, args ...interface{}) (*sql.Rows, error) {
+	db, err := connection()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	query := "CREATE TABLE `"
-	query += model.Name
-	query += "` ( "
-	for fieldName,fieldType  := range model.Fields {
-		query += fieldName
-		switch fieldType.Type {
-		case CharField:
-			query += " varchar(255)"
-		case TextField:
-			query += " TEXT"
-		case Integer:
-			query += " INT"
-		case Float:
-			query += " DOUBLE"
-		case Boolean:
-			query += " BOOL"
-		}
-		if fieldType.AutoIncrement {
-			query += " AUTO_INCREMENT"
-		}
-		if fieldType.NotNull {
-			query +=  " NOT NULL"
-		}
-		if fieldType.Unique {
-			query +=  " UNIQUE"
-		}
-		query += ","
-	}
-	if (model.hasBelongsTo()) {
-		if val, ok := model.BelongsTo.Fields[model.BelongsTo.PrimaryKey]; ok {
-			query += model.BelongsTo.Name + "_" + model.BelongsTo.PrimaryKey
-			switch val.Type {
-			case CharField:
-				query += " varchar(255)"
-			case TextField:
-				query += " TEXT"
-			case Integer:
-				query += " INT"
-			case Float:
-				query += " DOUBLE"
-			case Boolean:
-				query += " BOOL"
-			}
-			query += " NOT NULL,"
-		}
+	query = rebind(query)
+	start := time.Now()
+	result, err := db.Query(query, args...)
+	logQuery(context.Background(), query, args, time.Since(start), -1, err)
+	return result, err
+}
+
+//runExec runs a statement written with ? placeholders on the connection pool.
+func runExec(query string, args ...interface{}) (sql.Result, error) {
+	db, err := connection()
+	if err != nil {
+		return nil, err
 	}
-	if ( model.PrimaryKey != "" ) {
-		query += "PRIMARY KEY(" + model.PrimaryKey + ")"
-	} else {
-		query = query[:len(query)-1]
+	query = rebind(query)
+	start := time.Now()
+	result, err := db.Exec(query, args...)
+	logExec(context.Background(), query, args, start, result, err)
+	return result, err
+}
+
+//executor returns what the queries of the model run on : the transaction it is bound to or the connection pool.
+func (model *Model) executor() (executor, error) {
+	if (model.tx != nil) {
+		return model.tx.tx, nil
 	}
-	query += ")"
-	fmt.Println(query)
-	_, err = db.Query(query)
+	db, err := connection()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return db, nil
+}
+
+//query runs a query written with ? placeholders for the model, with its context (see Model.context). The rows have
+//to be closed, which logs the query. The errors of the driver are translated by the dialect.
+func (model *Model) query(query string, args ...interface{}) (*rows, error) {
+	e, err := model.executor()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := model.context()
+	query = rebind(query)
+	start := time.Now()
+	result, err := e.QueryContext(ctx, query, args...)
+	if err != nil {
+		logQuery(ctx, query, args, time.Since(start), -1, err)
+		cancel()
+		return nil, translate(err)
+	}
+	return &rows{Rows : result, cancel : cancel, ctx : ctx, query : query, args : args, duration : time.Since(start)}, nil
+}
+
+//exec runs a statement written with ? placeholders for the model, with its context. The errors of the driver are
+//translated by the dialect.
+func (model *Model) exec(query string, args ...interface{}) (sql.Result, error) {
+	e, err := model.executor()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := model.context()
+	defer cancel()
+	query = rebind(query)
+	start := time.Now()
+	result, err := e.ExecContext(ctx, query, args...)
+	logExec(ctx, query, args, start, result, err)
+	return result, translate(err)
+}
+
+//execSchema runs a statement changing the tables, with the context of the model but without the QueryTimeout, as
+//altering a large table can take long.
+func (model *Model) execSchema(stmt string) (sql.Result, error) {
+	e, err := model.executor()
+	if err != nil {
+		return nil, err
+	}
+	ctx := model.baseContext()
+	start := time.Now()
+	result, err := e.ExecContext(ctx, stmt)
+	logExec(ctx, stmt, nil, start, result, err)
+	return result, err
+}
+
+//AddToDatabase : Create a database for the corresponding Model
+//The join tables of its ManyToMany relations are made too, if they don't exist yet.
+func (model *Model) AddToDataBase() (error) {
+	for _, query := range append(model.createStatements(), model.joinStatements()...) {
+		_, err := model.execSchema(query)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -215,172 +432,216 @@ func (model *Model)GetAll()(error)  {
 	return err
 }
 
+//AddNewRecord inserts the object as a new record, after the BeforeCreate hook and the validators of the fields
+//(a failed validation returns ValidationErrors) and before the AfterCreate hook. It returns the primary key of the
+//record (given by the database for an auto incremented one) and the record as saved, with the values the database
+//filled in. A unique value already taken returns a DatabaseError of ErrDuplicate and another constraint violation one
+//of ErrConstraint.
 //
-func (model *Model) AddNewRecord (object Object) (error) {
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
+//    id, user, err := Users.AddNewRecord(object)
+func (model *Model) AddNewRecord (object Object) (interface{}, Object, error) {
+	err := model.runHook(model.BeforeCreate, object)
 	if err != nil {
-		return err
+		return nil, NewObject(), err
 	}
-	rows, err := db.Query("SELECT * FROM `"+model.Name+"`")
-	defer rows.Close()
+	object = model.stamped(object, true)
+	err = model.validate(object, true)
 	if err != nil {
-		return err
+		return nil, NewObject(), err
 	}
-	arr , err := rows.Columns()
+	columns := model.objectColumns(object)
+	if len(columns) == 0 {
+		return nil, NewObject(), errors.New("Error : The passed Object doesn't have any field of the model " + model.Name + ".")
+	}
+	args, err := model.insertValues(object, columns)
 	if err != nil {
-		return err
+		return nil, NewObject(), err
 	}
-	var query string = "INSERT INTO `" + model.Name + "`"
-	var columns string = " ("
-	var values string = " VALUES ("
-	for _,val := range arr {
-		if value,ok := object.Object[val] ; ok {
-			columns += "`" + val + "`,"
-			if _,ok := model.Fields[val] ; !ok {
-				model.Fields[val] = model.BelongsTo.Fields[val[len(model.BelongsTo.Name)+1:]]
-			}
-			switch model.Fields[val].Type {
-			case CharField,TextField:
-				values += "\""+value.(string)+"\","
-			case Integer:
-				values += fmt.Sprint(value.(int)) + ","
-			case Float:
-				values += fmt.Sprint(value.(float64)) + ","
-			case Boolean:
-				if value.(bool) {
-					values += "true,"
-				} else {
-					values += "false,"
-				}
+	stmt := "INSERT INTO " + quote(model.Name) + " (" + quoteAll(dialect, columns) + ") VALUES (" +
+		strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ")"
+	id, err := model.insert(object, stmt, args)
+	if err != nil {
+		return nil, NewObject(), err
+	}
+	saved := NewObject()
+	for name, value := range object.Object {
+		saved.Object[name] = value
+	}
+	if id != nil {
+		saved.Object[model.PrimaryKey] = id
+		records, err := model.GetRecord(model.PrimaryKey, id)
+		if err != nil {
+			return id, saved, err
+		}
+		if len(records) == 1 {
+			saved = records[0]
+		}
+	}
+	return id, saved, model.runHook(model.AfterCreate, saved)
+}
+
+//insert runs the INSERT statement of the object and returns the primary key of the new record : the one of the
+//object, or else the one the database gave to an auto incremented primary key (nil if there is none).
+func (model *Model) insert(object Object, stmt string, args []interface{}) (interface{}, error) {
+	if id, ok := object.Object[model.PrimaryKey]; ok && (id != nil) {
+		_, err := model.exec(stmt, args...)
+		return id, err
+	}
+	field := model.Fields[model.PrimaryKey]
+	if returning := dialect.Returning(model.PrimaryKey); returning != "" {
+		rows, err := model.query(stmt + returning, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var raw interface{}
+		if rows.Next() {
+			err = rows.Scan(&raw)
+			if err != nil {
+				return nil, translate(err)
 			}
 		}
+		if err = rows.Err(); err != nil {
+			return nil, translate(err)
+		}
+		if raw == nil {
+			return nil, nil
+		}
+		return decode(field, raw)
 	}
-	columns = columns[:len(columns)-1]+")"
-	values = values[:len(values)-1]+")"
-	fmt.Println(query+columns+values)
-	_, err = db.Query(query+columns+values)
+	result, err := model.exec(stmt, args...)
+	if (err != nil) || !field.AutoIncrement {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return err
+	return decode(field, id)
 }
 
-//
-func (model *Model) DeleteRecord(field string, value interface{})(error) {
-	query,err := model.FormStatement(field,value)
+//DeleteRecord deletes the records whose field has the value, calling the BeforeDelete and AfterDelete hooks with
+//each of them, and returns their number. The records of a model with SoftDelete are only marked as deleted (see
+//ForceDeleteRecord). When field is the primary key, ErrNotFound is returned if no record matched ; for the other
+//fields, no record matching is not an error and the number is 0. Deleting a record other records refer to without ON
+//DELETE CASCADE returns a DatabaseError of ErrConstraint.
+func (model *Model) DeleteRecord(field string, value interface{})(int, error) {
+	return model.deleteRecord(field, value, !model.SoftDelete)
+}
+
+//ForceDeleteRecord deletes the records whose field has the value like DeleteRecord, even those of a model with
+//SoftDelete.
+func (model *Model) ForceDeleteRecord(field string, value interface{})(int, error) {
+	return model.deleteRecord(field, value, true)
+}
+
+//deleteRecord deletes the records whose field has the value, or soft deletes those not deleted yet.
+func (model *Model) deleteRecord(field string, value interface{}, force bool)(int, error) {
+	query,args,err := model.FormStatement(field,value)
 	if err!= nil {
-		return err
+		return 0, err
 	}
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
-	if err != nil {
-		return err
+	var deleted Objects
+	if (model.BeforeDelete != nil) || (model.AfterDelete != nil) {
+		deleted, err = model.GetRecord(field, value)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, object := range deleted {
+		err = model.runHook(model.BeforeDelete, object)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	if force {
+		n,err = model.affected("DELETE FROM "+quote(model.Name)+" WHERE "+query, args...)
+	} else {
+		n,err = model.affected("UPDATE "+quote(model.Name)+" SET "+quote(DeletedAtColumn)+"=? WHERE "+query+" AND "+model.notDeleted(),
+			append([]interface{}{now()}, args...)...)
 	}
-	_,err = db.Query("DELETE FROM "+model.Name+" WHERE "+query)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return model.GetAll()
+	if (n == 0) && (field == model.PrimaryKey) {
+		return 0, ErrNotFound
+	}
+	for _, object := range deleted {
+		err = model.runHook(model.AfterDelete, object)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
 }
 
 //
 func (model *Model) GetRecord(field string, value interface{})(Objects,error) {
-	query,err := model.FormStatement(field,value)
+	query,args,err := model.FormStatement(field,value)
 	if err != nil {
 		return make(Objects,0),err
 	}
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
-	if err != nil {
-		return make(Objects,0),err
+	if notDeleted := model.notDeleted(); notDeleted != "" {
+		if query == "" {
+			query = notDeleted
+		} else {
+			query += " AND " + notDeleted
+		}
 	}
-	rows, err := db.Query("SELECT * FROM `"+model.Name+"` WHERE "+query)
-	fmt.Println("SELECT * FROM `"+model.Name+"` WHERE "+query)
 	if query == "" {
-		rows, err = db.Query("SELECT * FROM `"+model.Name+"`")
+		return model.DoQuery("SELECT * FROM "+quote(model.Name))
 	}
-	defer rows.Close()
+	return model.DoQueryArgs("SELECT * FROM "+quote(model.Name)+" WHERE "+query, args...)
+}
+
+//UpdateRecord sets the values of the object in the records whose field has the value, after the BeforeUpdate hook
+//and the validators of the fields in the object (a failed validation returns ValidationErrors) and before the
+//AfterUpdate hook, and returns the number of records matched, changed or not. When fieldName is the primary key,
+//ErrNotFound is returned if no record matched. The soft deleted records are left as they are. Constraint violations
+//return a DatabaseError of ErrDuplicate or ErrConstraint, like AddNewRecord.
+func (model *Model) UpdateRecord (object Object, fieldName string, value interface{}) (int, error) {
+	err := model.runHook(model.BeforeUpdate, object)
 	if err != nil {
-		return make(Objects,0),err
+		return 0, err
 	}
-	arr , err := rows.Columns()
+	object = model.stamped(object, false)
+	err = model.validate(object, false)
 	if err != nil {
-		return make(Objects,0),err
-	}
-	inner := make([]interface{},len(arr))
-	elements := make([]interface{},len(arr))
-	for i,_ := range inner {
-		elements[i] = &inner[i]
+		return 0, err
 	}
-	returnobj := make(Objects,0)
-	for rows.Next() {
-		tobj := Object{}
-		tobj.Object = make(map[string]interface{},0)
-		err = rows.Scan(elements...)
-		for i,val := range arr {
-			tstr := ""
-			if *elements[i].(*interface{}) != nil {
-				tstr = fmt.Sprintf("%#s",(*elements[i].(*interface{})).([]uint8))
-			}
-			switch model.Fields[val].Type {
-			case CharField,TextField:
-				tobj.Object[val] = tstr
-			case Integer:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.Atoi(tstr)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
-			case Float:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.ParseFloat(tstr, 64)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
-			case Boolean:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.ParseBool(tstr)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
-			}
-		}
-		returnobj = append(returnobj,tobj)
-	}
-	return returnobj,nil
-}
-
-//
-func (model *Model) UpdateRecord (object Object, fieldName string, value interface{}) (error) {
-	stmt := "UPDATE `" +model.Name+ "` SET "
+	stmt := "UPDATE " + quote(model.Name) + " SET "
+	var args []interface{}
 	for index,val := range object.Object {
-		temp,err := model.FormStatement(index,val)
+		temp,targs,err := model.FormStatement(index,val)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		stmt += temp +","
+		args = append(args, targs...)
 	}
 	stmt = string(stmt[:len(stmt)-1])
-	temp,err := model.FormStatement(fieldName,value)
+	temp,targs,err := model.FormStatement(fieldName,value)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	stmt += " WHERE " + temp
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
+	if notDeleted := model.notDeleted(); notDeleted != "" {
+		stmt += " AND " + notDeleted
+	}
+	args = append(args, targs...)
+	n,err := model.affected(stmt, args...)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	fmt.Println(stmt)
-	_,err = db.Query(stmt)
+	if (n == 0) && (fieldName == model.PrimaryKey) {
+		return 0, ErrNotFound
+	}
+	err = model.runHook(model.AfterUpdate, object)
 	if err != nil {
-		return err
+		return n, err
 	}
-	return model.GetAll()
+	return n, nil
 }
 
 //NewObject returns a Object struct object with the variables initialised.
@@ -391,54 +652,53 @@ func NewObject() (Object) {
 	return newobj
 }
 
-//
-func (model *Model) FormStatement (fieldName string, value interface{}) (string,error) {
+//FormStatement returns the "field=?" statement (with the field name quoted) for the field and the value to bind to the placeholder. Empty
+//strings are bound as NULL. The value is never put in the statement itself, so it is safe to pass user input.
+func (model *Model) FormStatement (fieldName string, value interface{}) (string,[]interface{},error) {
 	if fieldName == "" {
-		return "",nil
+		return "",nil,nil
 	}
-	val , ok := model.Fields[fieldName]
+	val , ok := model.field(fieldName)
 	if !ok {
-		if (model.hasBelongsTo()) {
-			val , ok = model.BelongsTo.Fields[fieldName[(len(model.BelongsTo.Name)+1):]]
-			if !ok {
-				return "",errors.New("Error : No such field "+fieldName[(len(model.BelongsTo.Name)+1):]+" in the model or it's owners.")
-			}
-		} else {
-			return "",errors.New("Error : No such field "+fieldName+" in the model")
-		}
+		return "",nil,errors.New("Error : No such field "+fieldName+" in the model or it's owners.")
 	}
-	stmt := fieldName + "="
+	stmt := quote(fieldName) + "=?"
 	switch val.Type {
 	case TextField,CharField :
-		if value.(string) != "" {
-			stmt += "\""+value.(string)+"\""
-		} else {
-			stmt += "NULL"
-		}
-	case Integer,Float :
-		stmt += fmt.Sprint(value)
-	case Boolean :
-		if value.(bool) {
-			stmt += "TRUE"
-		} else {
-			stmt += "FALSE"
+		if str, ok := value.(string); ok && str == "" {
+			return stmt, []interface{}{nil}, nil
 		}
 	}
-	return stmt, nil
+	value, err := encode(val, value)
+	if err != nil {
+		return "",nil,err
+	}
+	return stmt, []interface{}{value}, nil
 }
 
-//
+//DoQuery runs a raw query and returns the resulting rows as Objects, converting the columns according to the model's
+//fields.
 func (model *Model) DoQuery(rawquery string)(Objects,error) {
-	db, err := sql.Open("mysql", database.Username+":"+database.Password+"@/"+database.Database)
-	defer db.Close()
+	return model.DoQueryArgs(rawquery)
+}
+
+//DoQueryArgs is DoQuery with arguments bound to the ? placeholders in the query :
+//
+//    users.DoQueryArgs("SELECT * FROM users WHERE age > ? AND city = ?", 18, city)
+//
+//The ? placeholders are converted for the configured database (eg. to $1, $2 for PostgreSQL).
+func (model *Model) DoQueryArgs(query string, args ...interface{})(Objects,error) {
+	return model.doQuery(nil, query, args...)
+}
+
+//doQuery runs the query like DoQueryArgs, converting the columns which are not fields of the model (like the
+//aggregates of a Query) according to the extra fields.
+func (model *Model) doQuery(extra Fields, query string, args ...interface{})(Objects,error) {
+	rows, err := model.query(query, args...)
 	if err != nil {
 		return make(Objects,0),err
 	}
-	rows, err := db.Query(rawquery)
 	defer rows.Close()
-	if err != nil {
-		return make(Objects,0),err
-	}
 	arr , err := rows.Columns()
 	if err != nil {
 		return make(Objects,0),err
@@ -453,62 +713,199 @@ func (model *Model) DoQuery(rawquery string)(Objects,error) {
 		tobj := Object{}
 		tobj.Object = make(map[string]interface{},0)
 		err = rows.Scan(elements...)
+		if err != nil {
+			return make(Objects,0),err
+		}
 		for i,val := range arr {
-			tstr := ""
-			if *elements[i].(*interface{}) != nil {
-				tstr = fmt.Sprintf("%#s",(*elements[i].(*interface{})).([]uint8))
+			field, ok := extra[val]
+			if !ok {
+				field, _ = model.field(val)
 			}
-			switch model.Fields[val].Type {
-			case CharField,TextField:
-				tobj.Object[val] = tstr
-			case Integer:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.Atoi(tstr)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
-			case Float:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.ParseFloat(tstr, 64)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
-			case Boolean:
-				if tstr != "" {
-					tobj.Object[val],err = strconv.ParseBool(tstr)
-					if err != nil {
-						return make(Objects,0),err
-					}
-				}
+			value, err := decode(field, inner[i])
+			if err != nil {
+				return make(Objects,0),err
+			}
+			if value != nil {
+				tobj.Object[val] = value
 			}
 		}
 		returnobj = append(returnobj,tobj)
 	}
-	return returnobj,nil
+	return returnobj,rows.Err()
+}
+
+//decode converts a value scanned from a column to the Go type of the field (see the field types).
+//The drivers return different types for the same column (MySQL returns []byte for everything for example).
+//NULL values of text fields are decoded as "" and the other NULL values as nil.
+func decode(field Field, raw interface{}) (interface{}, error) {
+	if field.Type == Blob {
+		switch v := raw.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+		return nil, nil
+	}
+	if b, ok := raw.([]byte); ok {
+		raw = string(b)
+	}
+	switch field.Type {
+	case CharField,TextField:
+		if raw == nil {
+			return "", nil
+		}
+		return fmt.Sprint(raw), nil
+	case Integer:
+		switch v := raw.(type) {
+		case int64:
+			return int(v), nil
+		case string:
+			return strconv.Atoi(v)
+		}
+	case BigInteger:
+		switch v := raw.(type) {
+		case int64:
+			return v, nil
+		case string:
+			return strconv.ParseInt(v, 10, 64)
+		}
+	case Float:
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		case string:
+			return strconv.ParseFloat(v, 64)
+		}
+	case Boolean:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			return v != 0, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+	case DateTime,Date,Time:
+		switch v := raw.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			return parseTime(field.Type, v)
+		}
+	case Decimal:
+		switch v := raw.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		}
+	case JSON:
+		if v, ok := raw.(string); ok {
+			return json.RawMessage(v), nil
+		}
+	case UUID:
+		if raw != nil {
+			return fmt.Sprint(raw), nil
+		}
+	}
+	return nil, nil
+}
+
+//The layouts the drivers write the date and time values with, when they don't return a time.Time.
+var timeLayouts = map[Type][]string{
+	DateTime : {"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-01-02"},
+	Date     : {"2006-01-02"},
+	Time     : {"15:04:05.999999999", "15:04"},
+}
+
+//parseTime parses the text value of a DateTime, Date or Time column.
+func parseTime(fieldType Type, value string) (time.Time, error) {
+	if (fieldType == Date) && (len(value) > 10) {
+		value = value[:10]
+	}
+	var err error
+	for _, layout := range timeLayouts[fieldType] {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+//encode converts a value written to a column of the field to what the drivers expect : Date and Time values are
+//written as text, so only the date or the time of day is kept, and JSON values are encoded.
+func encode(field Field, value interface{}) (interface{}, error) {
+	switch field.Type {
+	case Date:
+		if t, ok := value.(time.Time); ok {
+			return t.Format("2006-01-02"), nil
+		}
+	case Time:
+		if t, ok := value.(time.Time); ok {
+			return t.Format("15:04:05.999999999"), nil
+		}
+	case JSON:
+		switch v := value.(type) {
+		case nil, string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		case json.RawMessage:
+			return string(v), nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return value, nil
+}
+
+//field returns the field with the given column name, which can be one of the timestamp columns. The column referring to the BelongsTo model
+//(<BelongsTo.Name>_<BelongsTo.PrimaryKey>) and those of the ForeignKey relations not in the Fields have the type of
+//the primary key they refer to.
+func (model *Model) field(name string) (Field, bool) {
+	if val, ok := model.Fields[name]; ok {
+		return val, true
+	}
+	if val, ok := model.managedFields()[name]; ok {
+		return val, true
+	}
+	if model.hasBelongsTo() && name == model.BelongsTo.Name + "_" + model.BelongsTo.PrimaryKey {
+		val, ok := model.BelongsTo.Fields[model.BelongsTo.PrimaryKey]
+		return val, ok
+	}
+	return model.foreignKeyField(name)
 }
 
 //
 func (model Model) hasBelongsTo () (bool) {
+	if (model.BelongsTo == nil) {
+		return false
+	}
 	if ((model.Name == model.BelongsTo.Name) || (model.BelongsTo.PrimaryKey == "") ) {
 		return false
 	}
 	return true
 }
 
+//GetAllRecordsBelongingTo returns the records of the model referring to the object of the BelongsTo model. Related
+//does the same for any relation.
 func (model *Model) GetAllRecordsBelongingTo(user Object) (Objects,error) {
 	if (! model.hasBelongsTo()) {
 		return make(Objects,0),errors.New("Error : The model passed ("+model.Name+") doesn't have a valid BelongsTo model Field")
 	}
 	if val , ok := user.Object[model.BelongsTo.PrimaryKey]; ok {
-		query := "SELECT * FROM `" + model.Name + "` WHERE " + model.BelongsTo.Name + "_"
-		wherestmt,err  := model.BelongsTo.FormStatement(model.BelongsTo.PrimaryKey, val)
-		if err != nil {
-			return make(Objects,0),err
-		}
-		query += wherestmt
-		return model.DoQuery(query)
+		query := "SELECT * FROM " + quote(model.Name) + " WHERE " + quote(model.BelongsTo.Name + "_" + model.BelongsTo.PrimaryKey) + "=?"
+		return model.DoQueryArgs(query, val)
 	}
 	return make(Objects,0),errors.New("Error : The passed Object doesn't have the required field.")
 }
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//testUsers returns a users model with a field of each type.
+func testUsers() *Model {
+	return &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":       {Type: Integer, AutoIncrement: true},
+		"Name":     {Type: CharField},
+		"Bio":      {Type: TextField},
+		"Age":      {Type: Integer},
+		"Visits":   {Type: BigInteger},
+		"Score":    {Type: Float},
+		"Admin":    {Type: Boolean},
+		"Born":     {Type: Date},
+		"Wakes":    {Type: Time},
+		"Joined":   {Type: DateTime},
+		"Balance":  {Type: Decimal, Precision: 10, Scale: 2},
+		"Settings": {Type: JSON},
+		"Avatar":   {Type: Blob},
+		"Token":    {Type: UUID},
+	}}
+}
+
+func TestEncode(t *testing.T) {
+	at := time.Date(2016, 10, 18, 12, 30, 15, 500, time.UTC)
+	tests := []struct {
+		fieldType Type
+		value     interface{}
+		want      interface{}
+	}{
+		{Date, at, "2016-10-18"},
+		{Time, at, "12:30:15.0000005"},
+		{DateTime, at, at},
+		{Date, "2016-10-18", "2016-10-18"},
+		{JSON, map[string]int{"a": 1}, `{"a":1}`},
+		{JSON, json.RawMessage(`[1,2]`), "[1,2]"},
+		{JSON, []byte(`{}`), "{}"},
+		{JSON, `"text"`, `"text"`},
+		{JSON, nil, nil},
+		{Integer, 5, 5},
+		{CharField, "x", "x"},
+	}
+	for _, test := range tests {
+		got, err := encode(Field{Type: test.fieldType}, test.value)
+		if err != nil {
+			t.Errorf("encode(%s, %v) : %v", test.fieldType, test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("encode(%s, %v) = %#v, want %#v", test.fieldType, test.value, got, test.want)
+		}
+	}
+	if _, err := encode(Field{Type: JSON}, make(chan int)); err == nil {
+		t.Error("encode(JSON, chan) : no error")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	at := time.Date(2016, 10, 18, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		fieldType Type
+		raw       interface{}
+		want      interface{}
+	}{
+		{CharField, []byte("salt"), "salt"},
+		{CharField, nil, ""},
+		{TextField, "text", "text"},
+		{Integer, int64(42), 42},
+		{Integer, []byte("42"), 42},
+		{BigInteger, int64(1) << 40, int64(1) << 40},
+		{BigInteger, "1099511627776", int64(1) << 40},
+		{Float, 1.5, 1.5},
+		{Float, int64(2), 2.0},
+		{Float, []byte("1.5"), 1.5},
+		{Boolean, true, true},
+		{Boolean, int64(0), false},
+		{Boolean, []byte("1"), true},
+		{DateTime, at, at},
+		{DateTime, "2016-10-18 12:30:15", at},
+		{Date, "2016-10-18T00:00:00Z", time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{Decimal, []byte("12.50"), "12.50"},
+		{Decimal, 12.5, "12.5"},
+		{Decimal, int64(12), "12"},
+		{JSON, []byte(`{"a":1}`), json.RawMessage(`{"a":1}`)},
+		{Blob, []byte{0, 255}, []byte{0, 255}},
+		{Blob, "ab", []byte("ab")},
+		{UUID, []byte("5f0c2a8e-0c8e-4b8e-9c5f-2c8e0c8e4b8e"), "5f0c2a8e-0c8e-4b8e-9c5f-2c8e0c8e4b8e"},
+		{Integer, nil, nil},
+		{DateTime, nil, nil},
+		{Blob, nil, nil},
+	}
+	for _, test := range tests {
+		got, err := decode(Field{Type: test.fieldType}, test.raw)
+		if err != nil {
+			t.Errorf("decode(%s, %#v) : %v", test.fieldType, test.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decode(%s, %#v) = %#v, want %#v", test.fieldType, test.raw, got, test.want)
+		}
+	}
+	if _, err := decode(Field{Type: Integer}, "four"); err == nil {
+		t.Error("decode(Integer, \"four\") : no error")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		fieldType Type
+		value     string
+		want      time.Time
+	}{
+		{DateTime, "2016-10-18 12:30:15", time.Date(2016, 10, 18, 12, 30, 15, 0, time.UTC)},
+		{DateTime, "2016-10-18 12:30:15.25+05:30", time.Date(2016, 10, 18, 12, 30, 15, 250000000, time.FixedZone("", 19800))},
+		{DateTime, "2016-10-18T12:30:15Z", time.Date(2016, 10, 18, 12, 30, 15, 0, time.UTC)},
+		{DateTime, "2016-10-18", time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{Date, "2016-10-18", time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{Date, "2016-10-18 00:00:00", time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{Time, "12:30:15.5", time.Date(0, 1, 1, 12, 30, 15, 500000000, time.UTC)},
+		{Time, "12:30", time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := parseTime(test.fieldType, test.value)
+		if err != nil {
+			t.Errorf("parseTime(%s, %q) : %v", test.fieldType, test.value, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseTime(%s, %q) = %v, want %v", test.fieldType, test.value, got, test.want)
+		}
+	}
+	if _, err := parseTime(Date, "yesterday"); err == nil {
+		t.Error("parseTime(Date, \"yesterday\") : no error")
+	}
+}
+
+func TestFormStatement(t *testing.T) {
+	useDialect(t, sqliteDialect{"sqlite"})
+	born := time.Date(2016, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		field string
+		value interface{}
+		stmt  string
+		args  []interface{}
+	}{
+		{"Name", "aki", `"Name"=?`, []interface{}{"aki"}},
+		//Empty strings are written as NULL.
+		{"Name", "", `"Name"=?`, []interface{}{nil}},
+		{"Bio", "", `"Bio"=?`, []interface{}{nil}},
+		{"Age", 0, `"Age"=?`, []interface{}{0}},
+		{"Born", born, `"Born"=?`, []interface{}{"2016-10-18"}},
+		{"Settings", map[string]bool{"dark": true}, `"Settings"=?`, []interface{}{`{"dark":true}`}},
+		{"Name", "x'; DROP TABLE users; --", `"Name"=?`, []interface{}{"x'; DROP TABLE users; --"}},
+		{"", "anything", "", nil},
+	}
+	users := testUsers()
+	for _, test := range tests {
+		stmt, args, err := users.FormStatement(test.field, test.value)
+		if err != nil {
+			t.Errorf("FormStatement(%q, %v) : %v", test.field, test.value, err)
+			continue
+		}
+		if (stmt != test.stmt) || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("FormStatement(%q, %v) = %q %#v, want %q %#v", test.field, test.value, stmt, args, test.stmt, test.args)
+		}
+	}
+	if _, _, err := users.FormStatement("Nope", 1); err == nil {
+		t.Error("FormStatement(\"Nope\", 1) : no error")
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+//Page is a page of the rows of a query, made by Paginate. Page is the number of the page (from 1), Pages the number
+//of pages and Total the number of rows of the query.
+type Page struct {
+	Items    Objects
+	Page     int
+	PerPage  int
+	Total    int
+	Pages    int
+	HasNext  bool
+	HasPrev  bool
+}
+
+//Next returns the number of the next page.
+func (p Page) Next() (int) {
+	return p.Page + 1
+}
+
+//Prev returns the number of the previous page.
+func (p Page) Prev() (int) {
+	return p.Page - 1
+}
+
+//Paginate runs the query for the rows of the given page, perPage rows a page, and counts all the rows. Pages before
+//the first are the first one ; pages after the last have no Items.
+//
+//    page, err := models.Paginate(posts.Query().OrderBy("-ID"), 2, 20)
+//
+//The query should be ordered, so the rows are in the same order on every page. Its Limit and Offset are ignored.
+func Paginate(q *Query, page int, perPage int) (Page, error) {
+	if perPage < 1 {
+		return Page{}, errors.New("Error : The number of rows per page should be at least 1")
+	}
+	if page < 1 {
+		page = 1
+	}
+	total, err := q.Count()
+	if err != nil {
+		return Page{}, err
+	}
+	paged := *q
+	paged.limit, paged.offset = perPage, (page - 1) * perPage
+	items, err := paged.All()
+	if err != nil {
+		return Page{}, err
+	}
+	pages := (total + perPage - 1) / perPage
+	return Page{
+		Items   : items,
+		Page    : page,
+		PerPage : perPage,
+		Total   : total,
+		Pages   : pages,
+		HasNext : page < pages,
+		HasPrev : page > 1,
+	}, nil
+}
+
+//CursorPage is a page of the rows of a query, made by PaginateAfter. Cursor is the value to pass to PaginateAfter for
+//the next page, nil when there is none.
+type CursorPage struct {
+	Items    Objects
+	PerPage  int
+	Cursor   interface{}
+	HasNext  bool
+	HasPrev  bool
+}
+
+//PaginateAfter runs the query for the perPage rows following the cursor (or the first ones if it is nil), ordered by
+//the column, in descending order if it starts with "-". Unlike Paginate, the rows are found with a condition on the
+//column instead of an OFFSET, so the pages far from the first one are as fast and the rows added in between don't
+//move the pages. The column should be unique, like the primary key :
+//
+//    page, err := models.PaginateAfter(posts.Query(), "-ID", r.URL.Query().Get("after"), 20)
+//
+//An empty string cursor is the same as nil. The order and Limit of the query are replaced.
+func PaginateAfter(q *Query, column string, cursor interface{}, perPage int) (CursorPage, error) {
+	if perPage < 1 {
+		return CursorPage{}, errors.New("Error : The number of rows per page should be at least 1")
+	}
+	if cursor == "" {
+		cursor = nil
+	}
+	operator := " >"
+	name := column
+	if strings.HasPrefix(column, "-") {
+		operator, name = " <", column[1:]
+	}
+	paged := *q
+	paged.order = nil
+	if cursor != nil {
+		if len(q.conditions) > 0 {
+			//The conditions of the query are put in parentheses, so its OR conditions don't bypass the cursor.
+			clause, args := q.joined()
+			paged.conditions = []condition{{false, "(" + clause + ")", args}}
+		}
+		paged.Where(name + operator, cursor)
+	}
+	paged.OrderBy(column)
+	paged.limit, paged.offset = perPage + 1, 0
+	items, err := paged.All()
+	if err != nil {
+		return CursorPage{}, err
+	}
+	page := CursorPage{Items : items, PerPage : perPage, HasPrev : cursor != nil}
+	if len(items) > perPage {
+		page.Items, page.HasNext = items[:perPage], true
+		page.Cursor = page.Items[perPage - 1].Object[name]
+	}
+	return page, nil
+}
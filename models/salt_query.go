@@ -0,0 +1,427 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//Query builds a SELECT on the table of a model. It is made with Model.Query and its methods can be chained :
+//
+//    objects, err := users.Query().Where("Age >", 18).Where("Name LIKE", "a%").OrderBy("-Created").Limit(20).Offset(40).All()
+//
+//A condition is a column name, optionally followed by an operator (= by default) :
+//
+//    =, !=, <>, <, <=, >, >=, LIKE, NOT LIKE   -  one value ; Where("Email", nil) is Email IS NULL
+//    IN, NOT IN                                -  the values, or a slice of them
+//    IS NULL, IS NOT NULL                      -  no value
+//    BETWEEN, NOT BETWEEN                      -  two values
+//
+//Conditions are joined with AND, or with OR by OrWhere, and WhereGroup puts conditions in parentheses :
+//
+//    users.Query().Where("Admin", true).WhereGroup(func(q *models.Query) {
+//        q.Where("Age <", 18).OrWhere("Age >", 65)
+//    })
+//
+//The column names are checked against the fields of the model and the values are always bound to placeholders, so
+//user input can be passed as values. The first error made while building the query is returned by the terminal
+//methods (All, First, Count, Exists, SQL).
+//
+//The methods change the Query they are called on and return it.
+type Query struct {
+	model       *Model
+	columns     []string
+	conditions  []condition
+	order       []string
+	limit       int
+	offset      int
+	preloads    []string
+	groupBy     []string
+	having      []condition
+	aggregates  Fields
+	withDeleted bool
+	onlyDeleted bool
+	err         error
+}
+
+//condition is one condition of the WHERE clause of a Query.
+type condition struct {
+	or   bool
+	sql  string
+	args []interface{}
+}
+
+//The LIMIT used when a Query only has an OFFSET, as not all databases support OFFSET alone.
+const noLimit = "9223372036854775807"
+
+//Query returns a new Query on the table of the model, selecting all the rows and columns.
+func (model *Model) Query() (*Query) {
+	return &Query{model : model, limit : -1}
+}
+
+//Where adds a condition joined with AND to the previous ones.
+func (q *Query) Where(condition string, values ...interface{}) (*Query) {
+	return q.add(false, condition, values)
+}
+
+//OrWhere adds a condition joined with OR to the previous ones.
+func (q *Query) OrWhere(condition string, values ...interface{}) (*Query) {
+	return q.add(true, condition, values)
+}
+
+//WhereGroup adds the conditions added by fn, in parentheses and joined with AND to the previous conditions.
+func (q *Query) WhereGroup(fn func(q *Query)) (*Query) {
+	return q.group(false, fn)
+}
+
+//OrWhereGroup adds the conditions added by fn, in parentheses and joined with OR to the previous conditions.
+func (q *Query) OrWhereGroup(fn func(q *Query)) (*Query) {
+	return q.group(true, fn)
+}
+
+//Select restricts the columns selected. All the columns are selected by default. Aggregates of the columns can be
+//selected too (see GroupBy).
+func (q *Query) Select(columns ...string) (*Query) {
+	for _, column := range columns {
+		if aggregate, ok := parseAggregate(column); ok {
+			field, err := q.aggregateField(aggregate)
+			if err != nil {
+				return q.fail(err)
+			}
+			if q.aggregates == nil {
+				q.aggregates = make(Fields)
+			}
+			q.aggregates[aggregate.alias] = field
+			continue
+		}
+		if !q.isColumn(column) {
+			return q.fail(errors.New("Error : No such field " + column + " in the model or it's owners."))
+		}
+	}
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+//OrderBy sorts the rows by the columns, in ascending order or descending if the column name starts with "-". The
+//aggregates selected before can be used, by their alias.
+func (q *Query) OrderBy(columns ...string) (*Query) {
+	for _, column := range columns {
+		direction := " ASC"
+		if strings.HasPrefix(column, "-") {
+			column, direction = column[1:], " DESC"
+		}
+		if _, ok := q.aggregates[column]; ok {
+			q.order = append(q.order, quote(column) + direction)
+			continue
+		}
+		if !q.isColumn(column) {
+			return q.fail(errors.New("Error : No such field " + column + " in the model or it's owners."))
+		}
+		q.order = append(q.order, quote(column) + direction)
+	}
+	return q
+}
+
+//Limit limits the number of rows returned.
+func (q *Query) Limit(n int) (*Query) {
+	if n < 0 {
+		return q.fail(errors.New("Error : The limit of a query can't be negative"))
+	}
+	q.limit = n
+	return q
+}
+
+//Offset skips the first n rows.
+func (q *Query) Offset(n int) (*Query) {
+	if n < 0 {
+		return q.fail(errors.New("Error : The offset of a query can't be negative"))
+	}
+	q.offset = n
+	return q
+}
+
+//All runs the query and returns the rows, with the relations passed to Preload.
+func (q *Query) All() (Objects, error) {
+	query, args, err := q.SQL()
+	if err != nil {
+		return make(Objects,0), err
+	}
+	objects, err := q.model.doQuery(q.aggregates, query, args...)
+	if err != nil {
+		return objects, err
+	}
+	for _, name := range q.preloads {
+		err = q.model.preload(name, objects)
+		if err != nil {
+			return objects, err
+		}
+	}
+	return objects, nil
+}
+
+//First runs the query for its first row. ErrNotFound is returned if there is no row.
+func (q *Query) First() (Object, error) {
+	limited := *q
+	limited.limit = 1
+	objects, err := limited.All()
+	if err != nil {
+		return NewObject(), err
+	}
+	if len(objects) == 0 {
+		return NewObject(), ErrNotFound
+	}
+	return objects[0], nil
+}
+
+//Count returns the number of rows matching the conditions of the query, or the number of groups of a grouped query.
+//Limit and Offset are ignored.
+func (q *Query) Count() (int, error) {
+	where, args, err := q.where()
+	if err != nil {
+		return 0, err
+	}
+	query := "SELECT COUNT(*) FROM " + quote(q.model.Name) + where
+	if len(q.groupBy) > 0 {
+		grouped, havingArgs := q.grouped()
+		query = "SELECT COUNT(*) FROM (SELECT 1 FROM " + quote(q.model.Name) + where + grouped + ") AS " + quote("salt_groups")
+		args = append(args, havingArgs...)
+	}
+	rows, err := q.model.query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var count int64
+	if rows.Next() {
+		err = rows.Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(count), rows.Err()
+}
+
+//Exists tells whether a row matches the conditions of the query.
+func (q *Query) Exists() (bool, error) {
+	where, args, err := q.where()
+	if err != nil {
+		return false, err
+	}
+	rows, err := q.model.query("SELECT 1 FROM " + quote(q.model.Name) + where + " LIMIT 1", args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	exists := rows.Next()
+	return exists, rows.Err()
+}
+
+//SQL returns the SELECT statement of the query, with ? placeholders, and the values bound to them.
+func (q *Query) SQL() (string, []interface{}, error) {
+	where, args, err := q.where()
+	if err != nil {
+		return "", nil, err
+	}
+	columns := "*"
+	if len(q.columns) > 0 {
+		var selected []string
+		for _, column := range q.columns {
+			selected = append(selected, selectExpression(column))
+		}
+		columns = strings.Join(selected, ", ")
+	}
+	query := "SELECT " + columns + " FROM " + quote(q.model.Name) + where
+	grouped, havingArgs := q.grouped()
+	query += grouped
+	args = append(args, havingArgs...)
+	if len(q.order) > 0 {
+		query += " ORDER BY " + strings.Join(q.order, ", ")
+	}
+	switch {
+	case q.limit >= 0:
+		query += " LIMIT " + strconv.Itoa(q.limit)
+	case q.offset > 0:
+		query += " LIMIT " + noLimit
+	}
+	if q.offset > 0 {
+		query += " OFFSET " + strconv.Itoa(q.offset)
+	}
+	return query, args, nil
+}
+
+//where returns the WHERE clause of the query (with a leading space) and its values. The soft deleted rows are left
+//out there.
+func (q *Query) where() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	filter := q.deletedFilter()
+	if len(q.conditions) == 0 {
+		if filter != "" {
+			return " WHERE " + filter, nil, nil
+		}
+		return "", nil, nil
+	}
+	clause, args := q.joined()
+	if filter != "" {
+		return " WHERE (" + clause + ") AND " + filter, args, nil
+	}
+	return " WHERE " + clause, args, nil
+}
+
+//joined returns the conditions of the query joined with AND and OR.
+func (q *Query) joined() (string, []interface{}) {
+	return joinConditions(q.conditions)
+}
+
+//joinConditions joins the conditions with AND and OR.
+func joinConditions(conditions []condition) (string, []interface{}) {
+	var clause string
+	var args []interface{}
+	for index, c := range conditions {
+		if index > 0 {
+			if c.or {
+				clause += " OR "
+			} else {
+				clause += " AND "
+			}
+		}
+		clause += c.sql
+		args = append(args, c.args...)
+	}
+	return clause, args
+}
+
+//fail records the first error made while building the query.
+func (q *Query) fail(err error) (*Query) {
+	if q.err == nil {
+		q.err = err
+	}
+	return q
+}
+
+//isColumn tells whether the column is one of the model's.
+func (q *Query) isColumn(column string) (bool) {
+	_, ok := q.model.field(column)
+	return ok
+}
+
+//add adds a condition to the query.
+func (q *Query) add(or bool, expression string, values []interface{}) (*Query) {
+	sql, args, err := q.condition(expression, values, false)
+	if err != nil {
+		return q.fail(err)
+	}
+	q.conditions = append(q.conditions, condition{or, sql, args})
+	return q
+}
+
+//group adds the conditions added by fn to a new query as one condition in parentheses.
+func (q *Query) group(or bool, fn func(q *Query)) (*Query) {
+	inner := q.model.Query()
+	fn(inner)
+	if inner.err != nil {
+		return q.fail(inner.err)
+	}
+	if len(inner.conditions) == 0 {
+		return q
+	}
+	sql, args := inner.joined()
+	q.conditions = append(q.conditions, condition{or, "(" + sql + ")", args})
+	return q
+}
+
+//condition compiles a condition of the Where methods into SQL with ? placeholders. The condition can be on an
+//aggregate if aggregates is set, for Having.
+func (q *Query) condition(expression string, values []interface{}, aggregates bool) (string, []interface{}, error) {
+	words := strings.Fields(expression)
+	if len(words) == 0 {
+		return "", nil, errors.New("Error : Empty query condition")
+	}
+	column, field, err := q.operand(words[0], aggregates)
+	if err != nil {
+		return "", nil, err
+	}
+	operator := "="
+	if len(words) > 1 {
+		operator = strings.ToUpper(strings.Join(words[1:], " "))
+	}
+	wrongValues := errors.New("Error : Wrong number of values for the condition " + expression)
+	if (operator == "IN") || (operator == "NOT IN") {
+		values = flatten(values)
+	} else {
+		values = append([]interface{}{}, values...)
+	}
+	for i, value := range values {
+		encoded, err := encode(field, value)
+		if err != nil {
+			return "", nil, err
+		}
+		values[i] = encoded
+	}
+	switch operator {
+	case "=", "!=", "<>", "<", "<=", ">", ">=", "LIKE", "NOT LIKE":
+		if len(values) != 1 {
+			return "", nil, wrongValues
+		}
+		if values[0] == nil {
+			switch operator {
+			case "=":
+				return column + " IS NULL", nil, nil
+			case "!=", "<>":
+				return column + " IS NOT NULL", nil, nil
+			}
+		}
+		return column + " " + operator + " ?", values, nil
+	case "IN", "NOT IN":
+		if len(values) == 0 {
+			//Nothing is IN an empty list.
+			if operator == "IN" {
+				return "1=0", nil, nil
+			}
+			return "1=1", nil, nil
+		}
+		return column + " " + operator + " (" + strings.TrimSuffix(strings.Repeat("?,", len(values)), ",") + ")", values, nil
+	case "IS NULL", "IS NOT NULL":
+		if len(values) != 0 {
+			return "", nil, wrongValues
+		}
+		return column + " " + operator, nil, nil
+	case "BETWEEN", "NOT BETWEEN":
+		if len(values) != 2 {
+			return "", nil, wrongValues
+		}
+		return column + " " + operator + " ? AND ?", values, nil
+	}
+	return "", nil, errors.New("Error : Unsupported operator " + operator + " in the condition " + expression)
+}
+
+//operand returns the SQL of the left side of a condition, a column or an aggregate, and the field of its values.
+func (q *Query) operand(operand string, aggregates bool) (string, Field, error) {
+	if aggregate, ok := parseAggregate(operand); aggregates && ok {
+		field, err := q.aggregateField(aggregate)
+		return aggregate.sql(), field, err
+	}
+	field, ok := q.model.field(operand)
+	if !ok {
+		return "", Field{}, errors.New("Error : No such field " + operand + " in the model or it's owners.")
+	}
+	return quote(operand), field, nil
+}
+
+//flatten returns the elements of a single slice value (except []byte) or else a copy of the values.
+func flatten(values []interface{}) ([]interface{}) {
+	if len(values) != 1 {
+		return append([]interface{}{}, values...)
+	}
+	v := reflect.ValueOf(values[0])
+	if (v.Kind() != reflect.Slice) || (v.Type().Elem().Kind() == reflect.Uint8) {
+		return append([]interface{}{}, values...)
+	}
+	flat := make([]interface{}, v.Len())
+	for i := range flat {
+		flat[i] = v.Index(i).Interface()
+	}
+	return flat
+}
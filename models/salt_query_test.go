@@ -0,0 +1,143 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCondition(t *testing.T) {
+	useDialect(t, sqliteDialect{"sqlite"})
+	tests := []struct {
+		expression string
+		values     []interface{}
+		sql        string
+		args       []interface{}
+	}{
+		{"Age", []interface{}{18}, `"Age" = ?`, []interface{}{18}},
+		{"Age >=", []interface{}{18}, `"Age" >= ?`, []interface{}{18}},
+		{"Name not like", []interface{}{"a%"}, `"Name" NOT LIKE ?`, []interface{}{"a%"}},
+		{"Name", []interface{}{nil}, `"Name" IS NULL`, nil},
+		{"Name !=", []interface{}{nil}, `"Name" IS NOT NULL`, nil},
+		{"Name IS NOT NULL", nil, `"Name" IS NOT NULL`, nil},
+		{"ID IN", []interface{}{1, 2, 3}, `"ID" IN (?,?,?)`, []interface{}{1, 2, 3}},
+		{"ID IN", []interface{}{[]int{1, 2}}, `"ID" IN (?,?)`, []interface{}{1, 2}},
+		{"ID NOT IN", []interface{}{[]string{"a"}}, `"ID" NOT IN (?)`, []interface{}{"a"}},
+		//Nothing is in an empty list.
+		{"ID IN", []interface{}{[]int{}}, "1=0", nil},
+		{"ID NOT IN", []interface{}{[]int{}}, "1=1", nil},
+		{"Age BETWEEN", []interface{}{18, 65}, `"Age" BETWEEN ? AND ?`, []interface{}{18, 65}},
+		{"Avatar", []interface{}{[]byte{1, 2}}, `"Avatar" = ?`, []interface{}{[]byte{1, 2}}},
+	}
+	users := testUsers()
+	for _, test := range tests {
+		sql, args, err := users.Query().condition(test.expression, test.values, false)
+		if err != nil {
+			t.Errorf("condition(%q, %v) : %v", test.expression, test.values, err)
+			continue
+		}
+		if (sql != test.sql) || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("condition(%q, %v) = %q %#v, want %q %#v", test.expression, test.values, sql, args, test.sql, test.args)
+		}
+	}
+	failing := []struct {
+		expression string
+		values     []interface{}
+	}{
+		{"", nil},
+		{"Nope", []interface{}{1}},
+		{"Age", []interface{}{1, 2}},
+		{"Age BETWEEN", []interface{}{1}},
+		{"Age IS NULL", []interface{}{1}},
+		{"Age ~", []interface{}{1}},
+	}
+	for _, test := range failing {
+		if _, _, err := users.Query().condition(test.expression, test.values, false); err == nil {
+			t.Errorf("condition(%q, %v) : no error", test.expression, test.values)
+		}
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	useDialect(t, sqliteDialect{"sqlite"})
+	users := testUsers()
+	notes := &Model{Name: "notes", PrimaryKey: "ID", SoftDelete: true, Fields: Fields{"ID": {Type: Integer}, "Body": {Type: TextField}}}
+	tests := []struct {
+		query *Query
+		sql   string
+		args  []interface{}
+	}{
+		{users.Query(), `SELECT * FROM "users"`, nil},
+		{users.Query().Where("Age >", 18).Where("Admin", true), `SELECT * FROM "users" WHERE "Age" > ? AND "Admin" = ?`, []interface{}{18, true}},
+		{users.Query().Where("Age <", 18).OrWhere("Age >", 65), `SELECT * FROM "users" WHERE "Age" < ? OR "Age" > ?`, []interface{}{18, 65}},
+		{users.Query().Where("Admin", true).WhereGroup(func(q *Query) {
+			q.Where("Age <", 18).OrWhere("Age >", 65)
+		}), `SELECT * FROM "users" WHERE "Admin" = ? AND ("Age" < ? OR "Age" > ?)`, []interface{}{true, 18, 65}},
+		{users.Query().Where("Admin", true).OrWhereGroup(func(q *Query) {
+			q.Where("Age >", 65).Where("Name IN", []string{})
+		}), `SELECT * FROM "users" WHERE "Admin" = ? OR ("Age" > ? AND 1=0)`, []interface{}{true, 65}},
+		//A group without conditions is left out.
+		{users.Query().WhereGroup(func(q *Query) {}), `SELECT * FROM "users"`, nil},
+		{users.Query().Select("ID", "Name").OrderBy("-Age", "Name").Limit(20).Offset(40),
+			`SELECT "ID", "Name" FROM "users" ORDER BY "Age" DESC, "Name" ASC LIMIT 20 OFFSET 40`, nil},
+		{users.Query().Offset(5), `SELECT * FROM "users" LIMIT ` + noLimit + ` OFFSET 5`, nil},
+		{notes.Query(), `SELECT * FROM "notes" WHERE "deleted_at" IS NULL`, nil},
+		{notes.Query().Where("ID", 1).OrWhere("ID", 2), `SELECT * FROM "notes" WHERE ("ID" = ? OR "ID" = ?) AND "deleted_at" IS NULL`, []interface{}{1, 2}},
+		{notes.Query().WithDeleted(), `SELECT * FROM "notes"`, nil},
+		{notes.Query().OnlyDeleted(), `SELECT * FROM "notes" WHERE "deleted_at" IS NOT NULL`, nil},
+	}
+	for _, test := range tests {
+		sql, args, err := test.query.SQL()
+		if err != nil {
+			t.Errorf("SQL() of %q : %v", test.sql, err)
+			continue
+		}
+		if (sql != test.sql) || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("SQL() = %q %#v, want %q %#v", sql, args, test.sql, test.args)
+		}
+	}
+	//The first error made while building the query is returned.
+	failing := []*Query{
+		users.Query().Where("Nope", 1).Where("Age", 1),
+		users.Query().Select("Nope"),
+		users.Query().OrderBy("-Nope"),
+		users.Query().Limit(-1),
+		users.Query().WhereGroup(func(q *Query) { q.Where("Age BETWEEN", 1) }),
+	}
+	for i, query := range failing {
+		if _, _, err := query.SQL(); err == nil {
+			t.Errorf("failing query %d : no error", i)
+		}
+	}
+}
+
+func TestAggregateSQL(t *testing.T) {
+	useDialect(t, sqliteDialect{"sqlite"})
+	users := testUsers()
+	tests := []struct {
+		query *Query
+		sql   string
+		args  []interface{}
+	}{
+		{users.Query().Select("Admin", "COUNT(*) AS Users", "avg(Age)").GroupBy("Admin"),
+			`SELECT "Admin", COUNT(*) AS "Users", AVG("Age") AS "avg(Age)" FROM "users" GROUP BY "Admin"`, nil},
+		{users.Query().Select("Age", "SUM(Score) AS Total").Where("Admin", false).GroupBy("Age").Having("COUNT(*) >=", 2).
+			Having("SUM(Score) <", 10).OrderBy("-Total"),
+			`SELECT "Age", SUM("Score") AS "Total" FROM "users" WHERE "Admin" = ? GROUP BY "Age" HAVING COUNT(*) >= ? AND SUM("Score") < ? ORDER BY "Total" DESC`,
+			[]interface{}{false, 2, 10}},
+	}
+	for _, test := range tests {
+		sql, args, err := test.query.SQL()
+		if (err != nil) || (sql != test.sql) || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("SQL() = %q %#v, %v, want %q %#v", sql, args, err, test.sql, test.args)
+		}
+	}
+	for _, query := range []*Query{
+		users.Query().GroupBy("Nope"),
+		users.Query().Select("SUM(*)").GroupBy("Age"),
+		users.Query().GroupBy("Age").Having("MEDIAN(Age) >", 1),
+	} {
+		if _, _, err := query.SQL(); err == nil {
+			t.Errorf("SQL() of %+v : no error", query)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//RelationKind is the kind of a Relation :
+//
+//    ForeignKey  -  the model has a column referring to the primary key of the other model (many to one)
+//    HasMany     -  the other model has a column referring to the primary key of this one (one to many)
+//    ManyToMany  -  the rows of the two models are linked by the rows of a join table
+type RelationKind string
+
+const(
+	ForeignKey    RelationKind = "foreignkey"
+	HasMany       RelationKind = "hasmany"
+	ManyToMany    RelationKind = "manytomany"
+)
+
+//Action is what the database does to the rows referring to a row when it is deleted or its primary key is updated.
+type Action string
+
+const(
+	Cascade       Action = "CASCADE"
+	SetNull       Action = "SET NULL"
+	SetDefault    Action = "SET DEFAULT"
+	Restrict      Action = "RESTRICT"
+	NoAction      Action = "NO ACTION"
+)
+
+//Relation is a relation of a model with another model, declared in the Relations of the model under the name it is
+//accessed with (see Related and Query.Preload) :
+//
+//    var Posts = models.Model{Name : "Posts", PrimaryKey : "ID", Fields : ..., Relations : map[string]models.Relation{
+//        "Author" : {Kind : models.ForeignKey, Model : &Users, OnDelete : models.Cascade},
+//        "Tags"   : {Kind : models.ManyToMany, Model : &Tags},
+//    }}
+//
+//    func init() {
+//        Users.Relations = map[string]models.Relation{"Posts" : {Kind : models.HasMany, Model : &Posts, Column : "Author_ID"}}
+//    }
+//
+//(Two models referring to each other can't both do it in their declaration, Go doesn't allow the initialization loop.)
+//
+//Column is the column referring to the other model : for a ForeignKey, the column of this model (<name>_<primary key of
+//the other model> by default), which is added to the table with a FOREIGN KEY constraint if it isn't one of the Fields ;
+//for HasMany, the column of the other model (<Name>_<PrimaryKey> by default, the column a ForeignKey named after this
+//model has). OnDelete and OnUpdate are the actions of the FOREIGN KEY constraint of a ForeignKey.
+//
+//The join table of a ManyToMany is made by salt with the table of the model, and is named JoinTable
+//(<first model name>_<second model name> by default, the names in alphabetical order, so the other model can declare
+//the reverse relation). It has a <Name>_<PrimaryKey> column for each model, deleted with the rows they refer to.
+//
+//The tables a model refers to have to exist before its own is made, so the models have to be registered in order.
+type Relation struct {
+	Kind          RelationKind
+	Model         *Model
+	Column        string
+	JoinTable     string
+	OnDelete      Action
+	OnUpdate      Action
+}
+
+//Reference is the FOREIGN KEY constraint of a column, referring to the Column of the Table. The constraints are named
+//<table>_<column>_fkey.
+type Reference struct {
+	Table         string
+	Column        string
+	OnDelete      Action `json:",omitempty"`
+	OnUpdate      Action `json:",omitempty"`
+}
+
+//foreignKeyName returns the name of the FOREIGN KEY constraint of a column.
+func foreignKeyName(table string, column string) (string) {
+	return table + "_" + column + "_fkey"
+}
+
+//references returns the REFERENCES clause of a foreign key.
+func references(d Dialect, reference *Reference) (string) {
+	clause := "REFERENCES " + d.Quote(reference.Table) + " (" + d.Quote(reference.Column) + ")"
+	if reference.OnDelete != "" {
+		clause += " ON DELETE " + string(reference.OnDelete)
+	}
+	if reference.OnUpdate != "" {
+		clause += " ON UPDATE " + string(reference.OnUpdate)
+	}
+	return clause
+}
+
+//foreignKey returns the FOREIGN KEY constraint of the column, as written in CREATE TABLE and ALTER TABLE statements.
+func foreignKey(d Dialect, table string, column Column) (string) {
+	return "CONSTRAINT " + d.Quote(foreignKeyName(table, column.Name)) + " FOREIGN KEY (" + d.Quote(column.Name) + ") " +
+		references(d, column.References)
+}
+
+//sameReference tells whether two columns refer to the same column with the same actions. No action is NO ACTION, as
+//the databases report it.
+func sameReference(a, b *Reference) (bool) {
+	if (a == nil) || (b == nil) {
+		return a == b
+	}
+	action := func(action Action) Action {
+		if action == "" {
+			return NoAction
+		}
+		return action
+	}
+	return (a.Table == b.Table) && (a.Column == b.Column) && (action(a.OnDelete) == action(b.OnDelete)) &&
+		(action(a.OnUpdate) == action(b.OnUpdate))
+}
+
+//relationNames returns the names of the relations of the model in order.
+func (model *Model) relationNames() ([]string) {
+	var names []string
+	for name := range model.Relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//relation returns the relation of the model with the given name.
+func (model *Model) relation(name string) (Relation, error) {
+	rel, ok := model.Relations[name]
+	if (!ok) || (rel.Model == nil) {
+		return Relation{}, errors.New("Error : No such relation " + name + " in the model " + model.Name)
+	}
+	return rel, nil
+}
+
+//related returns the other model of the relation, bound to the transaction and the context of the model.
+func (model *Model) related(rel Relation) (*Model) {
+	other := *rel.Model
+	other.tx, other.ctx = model.tx, model.ctx
+	return &other
+}
+
+//relationColumn returns the column referring to the other model of a ForeignKey or HasMany relation.
+func (model *Model) relationColumn(name string, rel Relation) (string) {
+	if (rel.Column != "") {
+		return rel.Column
+	}
+	if (rel.Kind == HasMany) {
+		return model.Name + "_" + model.PrimaryKey
+	}
+	return name + "_" + rel.Model.PrimaryKey
+}
+
+//referenceField returns the field of a column referring to the primary key of the model.
+func referenceField(model *Model) (Field) {
+	field := model.Fields[model.PrimaryKey]
+	return Field{Type : field.Type, MaxLength : field.MaxLength, Precision : field.Precision, Scale : field.Scale}
+}
+
+//foreignKeyField returns the field of the column of a ForeignKey relation which is not one of the Fields.
+func (model *Model) foreignKeyField(column string) (Field, bool) {
+	for _, name := range model.relationNames() {
+		rel := model.Relations[name]
+		if (rel.Kind == ForeignKey) && (rel.Model != nil) && (model.relationColumn(name, rel) == column) {
+			return referenceField(rel.Model), true
+		}
+	}
+	return Field{}, false
+}
+
+//joinTable returns the join table of a ManyToMany relation and its columns referring to the model and to the other one.
+func (model *Model) joinTable(name string, rel Relation) (table string, own string, other string) {
+	own = model.Name + "_" + model.PrimaryKey
+	other = rel.Model.Name + "_" + rel.Model.PrimaryKey
+	if (rel.Model.Name == model.Name) {
+		//A model related to itself refers to the other side with the name of the relation.
+		other = name + "_" + rel.Model.PrimaryKey
+	}
+	table = rel.JoinTable
+	if (table == "") {
+		names := []string{model.Name, rel.Model.Name}
+		if (rel.Model.Name == model.Name) {
+			names[1] = name
+		}
+		sort.Strings(names)
+		table = names[0] + "_" + names[1]
+	}
+	return table, own, other
+}
+
+//joinSchemas returns the schemas of the join tables of the ManyToMany relations of the model. The columns are sorted
+//by name and both make the primary key, so the reverse relation declares the same table.
+func (model *Model) joinSchemas() (map[string]tableSchema) {
+	schemas := make(map[string]tableSchema)
+	for _, name := range model.relationNames() {
+		rel := model.Relations[name]
+		if (rel.Kind != ManyToMany) || (rel.Model == nil) {
+			continue
+		}
+		table, own, other := model.joinTable(name, rel)
+		columns := []Column{
+			{Name : own, Type : dialect.ColumnType(referenceField(model)), NotNull : true, PrimaryKey : true,
+				References : &Reference{Table : model.Name, Column : model.PrimaryKey, OnDelete : Cascade, OnUpdate : Cascade}},
+			{Name : other, Type : dialect.ColumnType(referenceField(rel.Model)), NotNull : true, PrimaryKey : true,
+				References : &Reference{Table : rel.Model.Name, Column : rel.Model.PrimaryKey, OnDelete : Cascade, OnUpdate : Cascade}},
+		}
+		sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+		//The primary key covers the lookups on its first column, the index those on the second.
+		index := Index{Columns : []string{columns[1].Name}}
+		index.Name = indexName(table, index)
+		schemas[table] = tableSchema{Columns : columns, Indexes : []Index{index}}
+	}
+	return schemas
+}
+
+//joinStatements returns the statements creating the join tables of the model which don't exist yet.
+func (model *Model) joinStatements() ([]string) {
+	schemas := model.joinSchemas()
+	var stmts []string
+	for _, table := range sortedTables(schemas) {
+		join := Model{Name : table, tx : model.tx}
+		if !join.IsMigrated() {
+			stmts = append(stmts, createStatements(dialect, table, schemas[table])...)
+		}
+	}
+	return stmts
+}
+
+//checkRelations checks the relations of the model when it is registered.
+func (model *Model) checkRelations() (error) {
+	for _, name := range model.relationNames() {
+		rel := model.Relations[name]
+		if (rel.Model == nil) {
+			return errors.New("Error : The relation " + name + " of the model " + model.Name + " has no Model")
+		}
+		if _, ok := rel.Model.Fields[rel.Model.PrimaryKey]; !ok {
+			return errors.New("Error : The model " + rel.Model.Name + " of the relation " + name + " has no primary key field")
+		}
+		switch rel.Kind {
+		case ForeignKey, HasMany, ManyToMany:
+		default:
+			return errors.New("Error : The relation " + name + " of the model " + model.Name + " has an unknown Kind " + string(rel.Kind))
+		}
+	}
+	return nil
+}
+
+//Related returns a query on the rows of the other model related to the object by the relation : the row it refers
+//to for a ForeignKey, the rows referring to it for HasMany and the rows linked to it for ManyToMany. The query can
+//be refined before it is run :
+//
+//    posts, err := users.Related("Posts", user).OrderBy("-Created").Limit(10).All()
+//
+//The ids of a ManyToMany relation are read from the join table right away. The row a ForeignKey refers to is returned
+//even if it is soft deleted, like Preload does.
+func (model *Model) Related(name string, object Object) (*Query) {
+	rel, err := model.relation(name)
+	if err != nil {
+		return model.Query().fail(err)
+	}
+	other := model.related(rel)
+	switch rel.Kind {
+	case ForeignKey:
+		column := model.relationColumn(name, rel)
+		value, ok := object.Object[column]
+		if !ok {
+			return other.Query().fail(errors.New("Error : The passed Object doesn't have the field " + column + "."))
+		}
+		return other.Query().WithDeleted().Where(other.PrimaryKey, value)
+	case HasMany:
+		value, ok := object.Object[model.PrimaryKey]
+		if !ok {
+			return other.Query().fail(errors.New("Error : The passed Object doesn't have the field " + model.PrimaryKey + "."))
+		}
+		return other.Query().Where(model.relationColumn(name, rel), value)
+	}
+	value, ok := object.Object[model.PrimaryKey]
+	if !ok {
+		return other.Query().fail(errors.New("Error : The passed Object doesn't have the field " + model.PrimaryKey + "."))
+	}
+	links, err := model.links(name, rel, []interface{}{value})
+	if err != nil {
+		return other.Query().fail(err)
+	}
+	var ids []interface{}
+	for _, link := range links {
+		ids = append(ids, link[1])
+	}
+	return other.Query().Where(other.PrimaryKey + " IN", ids)
+}
+
+//links returns the rows of the join table of a ManyToMany relation linking the given primary keys of the model, as
+//pairs of the primary keys of the model and of the other model.
+func (model *Model) links(name string, rel Relation, values []interface{}) ([][2]interface{}, error) {
+	if (len(values) == 0) {
+		return nil, nil
+	}
+	table, own, other := model.joinTable(name, rel)
+	ownField, otherField := model.Fields[model.PrimaryKey], rel.Model.Fields[rel.Model.PrimaryKey]
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		encoded, err := encode(ownField, value)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = encoded
+	}
+	rows, err := model.query("SELECT " + quote(own) + "," + quote(other) + " FROM " + quote(table) + " WHERE " + quote(own) +
+		" IN (" + strings.TrimSuffix(strings.Repeat("?,", len(values)), ",") + ")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var links [][2]interface{}
+	for rows.Next() {
+		var ownRaw, otherRaw interface{}
+		err = rows.Scan(&ownRaw, &otherRaw)
+		if err != nil {
+			return nil, err
+		}
+		ownValue, err := decode(ownField, ownRaw)
+		if err != nil {
+			return nil, err
+		}
+		otherValue, err := decode(otherField, otherRaw)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, [2]interface{}{ownValue, otherValue})
+	}
+	return links, rows.Err()
+}
+
+//Link links the object to the others with the ManyToMany relation, adding rows to the join table.
+func (model *Model) Link(name string, object Object, others ...Object) (error) {
+	return model.changeLinks(name, object, others, true)
+}
+
+//Unlink removes the links of the ManyToMany relation between the object and the others, or all the links of the
+//object if no others are passed. The rows of the models are left as they are.
+func (model *Model) Unlink(name string, object Object, others ...Object) (error) {
+	return model.changeLinks(name, object, others, false)
+}
+
+//changeLinks adds or removes the rows of the join table linking the object to the others.
+func (model *Model) changeLinks(name string, object Object, others []Object, link bool) (error) {
+	rel, err := model.relation(name)
+	if err != nil {
+		return err
+	}
+	if (rel.Kind != ManyToMany) {
+		return errors.New("Error : The relation " + name + " of the model " + model.Name + " is not a ManyToMany relation")
+	}
+	table, own, other := model.joinTable(name, rel)
+	value, ok := object.Object[model.PrimaryKey]
+	if !ok {
+		return errors.New("Error : The passed Object doesn't have the field " + model.PrimaryKey + ".")
+	}
+	value, err = encode(model.Fields[model.PrimaryKey], value)
+	if err != nil {
+		return err
+	}
+	if !link && (len(others) == 0) {
+		_, err = model.exec("DELETE FROM " + quote(table) + " WHERE " + quote(own) + "=?", value)
+		return err
+	}
+	for _, linked := range others {
+		otherValue, ok := linked.Object[rel.Model.PrimaryKey]
+		if !ok {
+			return errors.New("Error : The passed Object doesn't have the field " + rel.Model.PrimaryKey + ".")
+		}
+		otherValue, err = encode(rel.Model.Fields[rel.Model.PrimaryKey], otherValue)
+		if err != nil {
+			return err
+		}
+		if link {
+			_, err = model.exec("INSERT INTO " + quote(table) + " (" + quote(own) + "," + quote(other) + ") VALUES (?,?)", value, otherValue)
+		} else {
+			_, err = model.exec("DELETE FROM " + quote(table) + " WHERE " + quote(own) + "=? AND " + quote(other) + "=?", value, otherValue)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Preload loads the relations of the rows returned by All and First with one more query per relation, instead of
+//one per row. The related rows are put in the objects under the name of the relation : an Object (or nil) for a
+//ForeignKey, Objects for HasMany and ManyToMany. The row a ForeignKey refers to is loaded even if it is soft deleted,
+//as the row still refers to it (its DeletedAtColumn tells), while the soft deleted HasMany and ManyToMany rows are
+//left out.
+//
+//    posts, err := posts.Query().Preload("Author", "Tags").All()
+//    author := posts[0].Object["Author"].(models.Object)
+func (q *Query) Preload(names ...string) (*Query) {
+	for _, name := range names {
+		if _, err := q.model.relation(name); err != nil {
+			return q.fail(err)
+		}
+	}
+	q.preloads = append(q.preloads, names...)
+	return q
+}
+
+//preload loads the relation of the objects.
+func (model *Model) preload(name string, objects Objects) (error) {
+	rel, err := model.relation(name)
+	if err != nil {
+		return err
+	}
+	other := model.related(rel)
+	switch rel.Kind {
+	case ForeignKey:
+		column := model.relationColumn(name, rel)
+		found, err := other.Query().WithDeleted().Where(other.PrimaryKey + " IN", distinct(objects, column)).All()
+		if err != nil {
+			return err
+		}
+		byKey := groupBy(found, other.PrimaryKey)
+		for _, object := range objects {
+			var parent interface{}
+			if matched, ok := byKey[key(object.Object[column])]; ok {
+				parent = matched[0]
+			}
+			object.Object[name] = parent
+		}
+		return nil
+	case HasMany:
+		column := model.relationColumn(name, rel)
+		found, err := other.Query().Where(column + " IN", distinct(objects, model.PrimaryKey)).All()
+		if err != nil {
+			return err
+		}
+		byKey := groupBy(found, column)
+		for _, object := range objects {
+			object.Object[name] = append(make(Objects, 0), byKey[key(object.Object[model.PrimaryKey])]...)
+		}
+		return nil
+	}
+	links, err := model.links(name, rel, distinct(objects, model.PrimaryKey))
+	if err != nil {
+		return err
+	}
+	var ids []interface{}
+	for _, link := range links {
+		ids = append(ids, link[1])
+	}
+	found, err := other.Query().Where(other.PrimaryKey + " IN", ids).All()
+	if err != nil {
+		return err
+	}
+	byKey := groupBy(found, other.PrimaryKey)
+	linked := make(map[string]Objects)
+	for _, link := range links {
+		if matched, ok := byKey[key(link[1])]; ok {
+			linked[key(link[0])] = append(linked[key(link[0])], matched[0])
+		}
+	}
+	for _, object := range objects {
+		object.Object[name] = append(make(Objects, 0), linked[key(object.Object[model.PrimaryKey])]...)
+	}
+	return nil
+}
+
+//key returns the key a value of a column is looked up with when the related rows are matched.
+func key(value interface{}) (string) {
+	return fmt.Sprint(value)
+}
+
+//distinct returns the distinct values of the column in the objects, leaving out NULL.
+func distinct(objects Objects, column string) ([]interface{}) {
+	seen := make(map[string]bool)
+	var values []interface{}
+	for _, object := range objects {
+		value, ok := object.Object[column]
+		if !ok || (value == nil) || seen[key(value)] {
+			continue
+		}
+		seen[key(value)] = true
+		values = append(values, value)
+	}
+	return values
+}
+
+//groupBy groups the objects by the value of the column.
+func groupBy(objects Objects, column string) (map[string]Objects) {
+	groups := make(map[string]Objects)
+	for _, object := range objects {
+		k := key(object.Object[column])
+		groups[k] = append(groups[k], object)
+	}
+	return groups
+}
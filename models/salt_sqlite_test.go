@@ -0,0 +1,1093 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "modernc.org/sqlite"
+)
+
+//useSQLite makes the models use a new SQLite database until the end of the test.
+func useSQLite(t *testing.T) {
+	previous := dialect
+	modelstore = nil
+	err := SetDatabaseConfig(Database{Driver: "sqlite", Database: filepath.Join(t.TempDir(), "salt.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		db, dialect, modelstore = nil, previous, nil
+	})
+}
+
+//count returns the number of rows of the model's table, the soft deleted ones included.
+func count(t *testing.T, model *Model) int {
+	n, err := model.Query().WithDeleted().Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSQLiteRecords(t *testing.T) {
+	useSQLite(t)
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Email": {Type: CharField, Unique: true, NotNull: true},
+		"Age":   {Type: Integer, Default: 18, Check: "Age >= 0"},
+	}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := users.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Fatalf("Diff of the new table = %q, %v", plan, err)
+	}
+	object := NewObject()
+	object.Object["Email"] = "a@example.com"
+	id, saved, err := users.AddNewRecord(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (id != 1) || (saved.Object["Age"] != 18) {
+		t.Errorf("AddNewRecord = %v, %v, want 1 and the default Age", id, saved.Object)
+	}
+	if _, _, err = users.AddNewRecord(object); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("AddNewRecord of the same Email : %v, want ErrDuplicate", err)
+	}
+	update := NewObject()
+	update.Object["Age"] = -1
+	if _, err = users.UpdateRecord(update, "ID", id); !errors.Is(err, ErrConstraint) {
+		t.Errorf("UpdateRecord breaking the check : %v, want ErrConstraint", err)
+	}
+	update.Object["Age"] = 31
+	if n, err := users.UpdateRecord(update, "ID", id); (n != 1) || (err != nil) {
+		t.Errorf("UpdateRecord = %d, %v, want 1", n, err)
+	}
+	//The same values are still counted.
+	if n, err := users.UpdateRecord(update, "ID", id); (n != 1) || (err != nil) {
+		t.Errorf("UpdateRecord with the same values = %d, %v, want 1", n, err)
+	}
+	if n, err := users.UpdateRecord(update, "ID", 99); (n != 0) || (err != ErrNotFound) {
+		t.Errorf("UpdateRecord of no record = %d, %v, want ErrNotFound", n, err)
+	}
+	if n, err := users.UpdateRecord(update, "Email", "b@example.com"); (n != 0) || (err != nil) {
+		t.Errorf("UpdateRecord of no record by Email = %d, %v, want 0", n, err)
+	}
+	if len(users.Objects) != 0 {
+		t.Errorf("UpdateRecord reloaded the Objects of the model : %v", users.Objects)
+	}
+	records, err := users.GetRecord("ID", id)
+	if (err != nil) || (len(records) != 1) || (records[0].Object["Age"] != 31) {
+		t.Errorf("GetRecord after UpdateRecord = %v, %v", records, err)
+	}
+	if n, err := users.DeleteRecord("ID", id); (n != 1) || (err != nil) {
+		t.Errorf("DeleteRecord = %d, %v, want 1", n, err)
+	}
+	if count(t, users) != 0 {
+		t.Error("the record is still there after DeleteRecord")
+	}
+	if n, err := users.DeleteRecord("ID", id); (n != 0) || (err != ErrNotFound) {
+		t.Errorf("DeleteRecord of no record = %d, %v, want ErrNotFound", n, err)
+	}
+	if n, err := users.DeleteRecord("Email", "a@example.com"); (n != 0) || (err != nil) {
+		t.Errorf("DeleteRecord of no record by Email = %d, %v, want 0", n, err)
+	}
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	useSQLite(t)
+	posts := &Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField},
+	}}
+	if err := posts.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := posts.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Fatalf("Diff of the new table = %q, %v", plan, err)
+	}
+	//A field is added to the model.
+	posts.Fields["Body"] = Field{Type: TextField}
+	want := []string{`ALTER TABLE "posts" ADD COLUMN "Body" TEXT`}
+	var out bytes.Buffer
+	SetQueryLogger(&Logger{Out: &out})
+	t.Cleanup(func() { SetQueryLogger(DefaultLogger) })
+	if plan, err := posts.Migrate(true, false); (err != nil) || !reflect.DeepEqual(plan, want) {
+		t.Errorf("Migrate of a dry run = %q, %v, want %q", plan, err, want)
+	}
+	if logged := "[salt] " + want[0] + ";\n"; out.String() != logged {
+		t.Errorf("the dry run logged %q, want %q", out.String(), logged)
+	}
+	if plan, err := posts.Diff(); (err != nil) || !reflect.DeepEqual(plan, want) {
+		t.Errorf("Diff after a dry run = %q, %v, want %q", plan, err, want)
+	}
+	if plan, err := posts.Migrate(false, false); (err != nil) || !reflect.DeepEqual(plan, want) {
+		t.Errorf("Migrate = %q, %v, want %q", plan, err, want)
+	}
+	if plan, err := posts.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after Migrate = %q, %v", plan, err)
+	}
+	//A field is removed from the model : its column is only dropped with dropColumns.
+	delete(posts.Fields, "Title")
+	if plan, err := posts.Migrate(false, false); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Migrate without dropColumns = %q, %v", plan, err)
+	}
+	want = []string{`ALTER TABLE "posts" DROP COLUMN "Title"`}
+	if plan, err := posts.Migrate(false, true); (err != nil) || !reflect.DeepEqual(plan, want) {
+		t.Errorf("Migrate = %q, %v, want %q", plan, err, want)
+	}
+	if plan, err := posts.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after Migrate = %q, %v", plan, err)
+	}
+}
+
+func TestSQLiteRebuild(t *testing.T) {
+	useSQLite(t)
+	dir := t.TempDir()
+	authors := &Model{Name: "authors", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	posts := &Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField},
+	}, Relations: map[string]Relation{"Author": {Kind: ForeignKey, Model: authors, OnDelete: Cascade}}}
+	for _, model := range []*Model{authors, posts} {
+		if err := model.Register(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := MakeMigrations(dir, "initial", Models{*authors, *posts}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FakeMigrations(dir); err != nil {
+		t.Fatal(err)
+	}
+	add := func(model *Model, values map[string]interface{}) error {
+		object := NewObject()
+		for field, value := range values {
+			object.Object[field] = value
+		}
+		_, _, err := model.AddNewRecord(object)
+		return err
+	}
+	if err := add(authors, map[string]interface{}{"Name": "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := add(posts, map[string]interface{}{"Title": "first", "Author_ID": 1}); err != nil {
+		t.Fatal(err)
+	}
+	//SQLite can't alter a column, the table is rebuilt, without deleting the posts referring to it.
+	authors.Fields["Name"] = Field{Type: CharField, NotNull: true, Index: true}
+	plan, err := authors.Migrate(false, false)
+	if (err != nil) || (len(plan) != 5) || !strings.HasPrefix(plan[0], `CREATE TABLE "salt_rebuild_authors"`) {
+		t.Fatalf("Migrate = %q, %v, want the table rebuilt", plan, err)
+	}
+	if plan, err := authors.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after the rebuild = %q, %v", plan, err)
+	}
+	if (count(t, authors) != 1) || (count(t, posts) != 1) {
+		t.Errorf("%d authors and %d posts after the rebuild, want 1 and 1", count(t, authors), count(t, posts))
+	}
+	if err := add(authors, map[string]interface{}{"Name": nil}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("AddNewRecord without the NOT NULL Name : %v, want ErrConstraint", err)
+	}
+	if err := add(posts, map[string]interface{}{"Title": "lost", "Author_ID": 99}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("AddNewRecord of a post of no author after the rebuild : %v, want ErrConstraint", err)
+	}
+	//The versioned migrations rebuild the table in their transaction.
+	authors.Fields["Name"] = Field{Type: CharField, NotNull: true, Index: true, Default: "anonymous"}
+	if _, err := MakeMigrations(dir, "authors_name", Models{*authors, *posts}); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := ApplyMigrations(dir); (err != nil) || (len(applied) != 1) {
+		t.Fatalf("ApplyMigrations = %v, %v", applied, err)
+	}
+	if plan, err := authors.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after ApplyMigrations = %q, %v", plan, err)
+	}
+	if (count(t, authors) != 1) || (count(t, posts) != 1) {
+		t.Errorf("%d authors and %d posts after ApplyMigrations, want 1 and 1", count(t, authors), count(t, posts))
+	}
+	records, err := authors.GetRecord("ID", 1)
+	if (err != nil) || (len(records) != 1) || (records[0].Object["Name"] != "ann") {
+		t.Errorf("GetRecord after ApplyMigrations = %v, %v", records, err)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	useSQLite(t)
+	dir := t.TempDir()
+	posts := Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField},
+	}}
+	//Both migrations are made in the same second, their names keep them in order.
+	first, err := MakeMigrations(dir, "create_posts", Models{posts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MakeMigrations(dir, "again", Models{posts}); err != ErrNoChanges {
+		t.Errorf("MakeMigrations without changes : %v, want ErrNoChanges", err)
+	}
+	posts.Fields["Body"] = Field{Type: TextField}
+	second, err := MakeMigrations(dir, "posts_body", Models{posts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := ApplyMigrations(dir)
+	if (err != nil) || !reflect.DeepEqual(applied, []string{first, second}) {
+		t.Fatalf("ApplyMigrations = %v, %v, want %v", applied, err, []string{first, second})
+	}
+	if plan, err := posts.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after ApplyMigrations = %q, %v", plan, err)
+	}
+	if pending, err := PendingMigrations(dir); (err != nil) || (len(pending) != 0) {
+		t.Errorf("PendingMigrations after ApplyMigrations = %v, %v", pending, err)
+	}
+	reverted, err := RollbackMigrations(dir, 1)
+	if (err != nil) || !reflect.DeepEqual(reverted, []string{second}) {
+		t.Errorf("RollbackMigrations = %v, %v, want %v", reverted, err, []string{second})
+	}
+	delete(posts.Fields, "Body")
+	if plan, err := posts.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Errorf("Diff after RollbackMigrations = %q, %v", plan, err)
+	}
+	if pending, err := PendingMigrations(dir); (err != nil) || !reflect.DeepEqual(pending, []string{second}) {
+		t.Errorf("PendingMigrations after RollbackMigrations = %v, %v", pending, err)
+	}
+	if _, err := RollbackMigrations(dir, 5); err != nil {
+		t.Error(err)
+	}
+	if posts.IsMigrated() {
+		t.Error("the table is still there after rolling back every migration")
+	}
+	//A database which already has the table only records the migrations as applied.
+	posts.Fields["Body"] = Field{Type: TextField}
+	if err := posts.Register(); err != nil {
+		t.Fatal(err)
+	}
+	faked, err := FakeMigrations(dir)
+	if (err != nil) || !reflect.DeepEqual(faked, []string{first, second}) {
+		t.Errorf("FakeMigrations = %v, %v, want %v", faked, err, []string{first, second})
+	}
+	if pending, err := PendingMigrations(dir); (err != nil) || (len(pending) != 0) {
+		t.Errorf("PendingMigrations after FakeMigrations = %v, %v", pending, err)
+	}
+	if applied, err := ApplyMigrations(dir); (err != nil) || (len(applied) != 0) {
+		t.Errorf("ApplyMigrations after FakeMigrations = %v, %v", applied, err)
+	}
+}
+
+func TestSQLiteTransaction(t *testing.T) {
+	useSQLite(t)
+	tags := &Model{Name: "tags", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField, Unique: true},
+	}}
+	if err := tags.Register(); err != nil {
+		t.Fatal(err)
+	}
+	add := func(model *Model, name string) error {
+		object := NewObject()
+		object.Object["Name"] = name
+		_, _, err := model.AddNewRecord(object)
+		return err
+	}
+	failed := errors.New("failed")
+	err := Transaction(func(tx *Tx) error {
+		if err := add(tx.Model(tags), "go"); err != nil {
+			return err
+		}
+		//Only the savepoint is rolled back.
+		err := tx.Transaction(func(tx *Tx) error {
+			if err := add(tx.Model(tags), "sql"); err != nil {
+				return err
+			}
+			return failed
+		})
+		if err != failed {
+			t.Errorf("nested Transaction returned %v, want %v", err, failed)
+		}
+		return add(tx.Model(tags), "web")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, tags); n != 2 {
+		t.Errorf("%d tags after the transaction, want 2", n)
+	}
+	//The whole transaction is rolled back.
+	err = Transaction(func(tx *Tx) error {
+		if err := add(tx.Model(tags), "db"); err != nil {
+			return err
+		}
+		return add(tx.Model(tags), "go")
+	})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Transaction returned %v, want ErrDuplicate", err)
+	}
+	if n := count(t, tags); n != 2 {
+		t.Errorf("%d tags after the rolled back transaction, want 2", n)
+	}
+	//A panic rolls the transaction back too.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the panic of the transaction was not passed on")
+			}
+		}()
+		Transaction(func(tx *Tx) error {
+			add(tx.Model(tags), "db")
+			panic("failed")
+		})
+	}()
+	if n := count(t, tags); n != 2 {
+		t.Errorf("%d tags after the panicking transaction, want 2", n)
+	}
+	//The savepoints are logged like the other statements, and a failed rollback is returned with the error.
+	logged := &events{}
+	SetQueryLogger(logged)
+	t.Cleanup(func() { SetQueryLogger(DefaultLogger) })
+	err = Transaction(func(tx *Tx) error {
+		return tx.Transaction(func(tx *Tx) error {
+			if _, err := tx.Exec("RELEASE SAVEPOINT salt_savepoint_1"); err != nil {
+				return err
+			}
+			return failed
+		})
+	})
+	if !errors.Is(err, failed) || !strings.Contains(err.Error(), "rollback failed") {
+		t.Errorf("Transaction with a failed rollback returned %v", err)
+	}
+	var savepoints []string
+	var rollbackErr error
+	for _, event := range logged.list {
+		if strings.Contains(event.Query, "SAVEPOINT") {
+			savepoints = append(savepoints, event.Query)
+			rollbackErr = event.Err
+		}
+	}
+	want := []string{"SAVEPOINT salt_savepoint_1", "RELEASE SAVEPOINT salt_savepoint_1", "ROLLBACK TO SAVEPOINT salt_savepoint_1"}
+	if !reflect.DeepEqual(savepoints, want) || (rollbackErr == nil) {
+		t.Errorf("logged savepoints %q (the rollback failing with %v), want %q", savepoints, rollbackErr, want)
+	}
+
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	useSQLite(t)
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+		"Age":  {Type: Integer},
+	}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"ann", "bob", "cid", "dan"} {
+		object := NewObject()
+		object.Object["Name"] = name
+		object.Object["Age"] = 20 + 10*i
+		if _, _, err := users.AddNewRecord(object); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := users.Query().Where("Age >", 25).OrderBy("-Age").Limit(2).All()
+	if (err != nil) || (len(records) != 2) || (records[0].Object["Name"] != "dan") || (records[1].Object["Name"] != "cid") {
+		t.Errorf("All = %v, %v, want dan and cid", records, err)
+	}
+	first, err := users.Query().Where("Name IN", []string{"bob", "cid"}).OrderBy("Name").First()
+	if (err != nil) || (first.Object["Name"] != "bob") {
+		t.Errorf("First = %v, %v, want bob", first.Object, err)
+	}
+	if _, err := users.Query().Where("Age >", 100).First(); err != ErrNotFound {
+		t.Errorf("First of no rows : %v, want ErrNotFound", err)
+	}
+	if n, err := users.Query().Where("Age BETWEEN", 20, 40).Count(); (n != 3) || (err != nil) {
+		t.Errorf("Count = %d, %v, want 3", n, err)
+	}
+	if exists, err := users.Query().Where("Name", "eve").Exists(); exists || (err != nil) {
+		t.Errorf("Exists = %v, %v, want false", exists, err)
+	}
+}
+
+type member struct {
+	ID   int `salt:"pk,autoinc"`
+	Name string `salt:"unique"`
+	Nick sql.NullString
+}
+
+func TestSQLiteStruct(t *testing.T) {
+	useSQLite(t)
+	members, err := FromStruct(&member{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = members.Register(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []member{{Name: "ann", Nick: sql.NullString{String: "a", Valid: true}}, {Name: "bob"}} {
+		object, err := ToObject(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := object.Object["ID"]; ok {
+			t.Error("ToObject kept the zero auto incremented ID")
+		}
+		if _, _, err = members.AddNewRecord(object); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var all []member
+	if err = members.Query().OrderBy("Name").Into(&all); err != nil {
+		t.Fatal(err)
+	}
+	want := []member{{1, "ann", sql.NullString{String: "a", Valid: true}}, {2, "bob", sql.NullString{}}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("Into = %+v, want %+v", all, want)
+	}
+	var one member
+	if err = members.Query().Where("Name", "eve").Into(&one); err != ErrNotFound {
+		t.Errorf("Into of no row : %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteSchema(t *testing.T) {
+	useSQLite(t)
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Email": {Type: CharField, Index: true},
+		"Age":   {Type: Integer, Default: 18, Check: "Age >= 0"},
+	}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := users.Diff(); (err != nil) || (len(plan) != 0) {
+		t.Fatalf("Diff of the new table = %q, %v", plan, err)
+	}
+	object := NewObject()
+	object.Object["Email"] = "a@example.com"
+	if _, _, err := users.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	records, err := users.GetRecord("Email", "a@example.com")
+	if (err != nil) || (len(records) != 1) || (records[0].Object["Age"] != 18) {
+		t.Errorf("GetRecord = %v, %v, want the default Age", records, err)
+	}
+	object.Object["Age"] = -1
+	if _, _, err := users.AddNewRecord(object); !errors.Is(err, ErrConstraint) {
+		t.Errorf("AddNewRecord breaking the check : %v, want ErrConstraint", err)
+	}
+	//Dropping the index is planned once the field no longer has it.
+	users.Fields["Email"] = Field{Type: CharField}
+	if plan, err := users.Diff(); (err != nil) || (len(plan) != 1) || !strings.HasPrefix(plan[0], "DROP INDEX") {
+		t.Errorf("Diff without the index = %q, %v", plan, err)
+	}
+}
+
+func TestSQLiteRelations(t *testing.T) {
+	useSQLite(t)
+	authors := &Model{Name: "authors", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	tags := &Model{Name: "tags", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	posts := &Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField},
+	}, Relations: map[string]Relation{
+		"Author": {Kind: ForeignKey, Model: authors, OnDelete: Cascade},
+		"Tags":   {Kind: ManyToMany, Model: tags},
+	}}
+	authors.Relations = map[string]Relation{"Posts": {Kind: HasMany, Model: posts, Column: "Author_ID"}}
+	for _, model := range []*Model{authors, tags, posts} {
+		if err := model.Register(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add := func(model *Model, values map[string]interface{}) Object {
+		object := NewObject()
+		for field, value := range values {
+			object.Object[field] = value
+		}
+		if _, _, err := model.AddNewRecord(object); err != nil {
+			t.Fatal(err)
+		}
+		added, err := model.Query().OrderBy("-ID").First()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return added
+	}
+	ann, bob := add(authors, map[string]interface{}{"Name": "ann"}), add(authors, map[string]interface{}{"Name": "bob"})
+	goTag, sqlTag := add(tags, map[string]interface{}{"Name": "go"}), add(tags, map[string]interface{}{"Name": "sql"})
+	first := add(posts, map[string]interface{}{"Title": "first", "Author_ID": ann.Object["ID"]})
+	second := add(posts, map[string]interface{}{"Title": "second", "Author_ID": ann.Object["ID"]})
+	add(posts, map[string]interface{}{"Title": "third", "Author_ID": bob.Object["ID"]})
+	if _, _, err := posts.AddNewRecord(Object{Object: map[string]interface{}{"Title": "orphan", "Author_ID": 99}}); !errors.Is(err, ErrConstraint) {
+		t.Errorf("AddNewRecord referring to no author : %v, want ErrConstraint", err)
+	}
+
+	author, err := posts.Related("Author", second).First()
+	if (err != nil) || (author.Object["Name"] != "ann") {
+		t.Errorf("Related Author = %v, %v, want ann", author.Object, err)
+	}
+	if n, err := authors.Related("Posts", ann).Count(); (n != 2) || (err != nil) {
+		t.Errorf("Related Posts count = %d, %v, want 2", n, err)
+	}
+	if err := posts.Link("Tags", first, goTag, sqlTag); err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.Link("Tags", second, sqlTag); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := posts.Related("Tags", first).OrderBy("Name").All()
+	if (err != nil) || (len(linked) != 2) || (linked[0].Object["Name"] != "go") {
+		t.Errorf("Related Tags = %v, %v, want go and sql", linked, err)
+	}
+
+	all, err := posts.Query().Preload("Author", "Tags").OrderBy("ID").All()
+	if (err != nil) || (len(all) != 3) {
+		t.Fatalf("Preload = %v, %v", all, err)
+	}
+	for i, want := range []struct {
+		author string
+		tags   int
+	}{{"ann", 2}, {"ann", 1}, {"bob", 0}} {
+		parent, _ := all[i].Object["Author"].(Object)
+		if (parent.Object == nil) || (parent.Object["Name"] != want.author) {
+			t.Errorf("post %d : Author = %v, want %s", i, all[i].Object["Author"], want.author)
+		}
+		if n := len(all[i].Object["Tags"].(Objects)); n != want.tags {
+			t.Errorf("post %d : %d Tags, want %d", i, n, want.tags)
+		}
+	}
+	withPosts, err := authors.Query().Preload("Posts").OrderBy("ID").All()
+	if (err != nil) || (len(withPosts[0].Object["Posts"].(Objects)) != 2) || (len(withPosts[1].Object["Posts"].(Objects)) != 1) {
+		t.Errorf("Preload Posts = %v, %v", withPosts, err)
+	}
+
+	if err := posts.Unlink("Tags", first, goTag); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := posts.Related("Tags", first).Count(); (n != 1) || (err != nil) {
+		t.Errorf("Related Tags count after Unlink = %d, %v, want 1", n, err)
+	}
+	//The posts of a deleted author are deleted with it.
+	if _, err := authors.DeleteRecord("ID", ann.Object["ID"]); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, posts); n != 1 {
+		t.Errorf("%d posts after deleting their author, want 1", n)
+	}
+}
+
+func TestSQLiteRelationsSoftDelete(t *testing.T) {
+	useSQLite(t)
+	authors := &Model{Name: "authors", PrimaryKey: "ID", SoftDelete: true, Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	posts := &Model{Name: "posts", PrimaryKey: "ID", SoftDelete: true, Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField},
+	}, Relations: map[string]Relation{"Author": {Kind: ForeignKey, Model: authors}}}
+	authors.Relations = map[string]Relation{"Posts": {Kind: HasMany, Model: posts, Column: "Author_ID"}}
+	for _, model := range []*Model{authors, posts} {
+		if err := model.Register(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	author := NewObject()
+	author.Object["Name"] = "ann"
+	id, author, err := authors.AddNewRecord(author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"kept", "deleted"} {
+		post := NewObject()
+		post.Object["Title"], post.Object["Author_ID"] = title, id
+		if _, _, err := posts.AddNewRecord(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := posts.DeleteRecord("Title", "deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authors.DeleteRecord("ID", id); err != nil {
+		t.Fatal(err)
+	}
+	//The post still refers to its soft deleted author, which is loaded with its deleted_at.
+	found, err := posts.Query().Preload("Author").All()
+	if (err != nil) || (len(found) != 1) {
+		t.Fatalf("Preload = %v, %v", found, err)
+	}
+	parent, ok := found[0].Object["Author"].(Object)
+	if !ok || (parent.Object["Name"] != "ann") || (parent.Object[DeletedAtColumn] == nil) {
+		t.Errorf("the preloaded author of the post is %v", found[0].Object["Author"])
+	}
+	if related, err := posts.Related("Author", found[0]).All(); (err != nil) || (len(related) != 1) {
+		t.Errorf("Related author of the post = %v, %v", related, err)
+	}
+	//The soft deleted posts are left out of the other side.
+	if related, err := authors.Related("Posts", author).All(); (err != nil) || (len(related) != 1) || (related[0].Object["Title"] != "kept") {
+		t.Errorf("Related posts of the author = %v, %v", related, err)
+	}
+}
+
+func TestSQLiteHooks(t *testing.T) {
+	useSQLite(t)
+	events := &Model{Name: "events", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	posts := &Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Title": {Type: CharField, Validators: []Validator{Required()}},
+		"Slug":  {Type: CharField, Validators: []Validator{Required()}},
+	}}
+	for _, model := range []*Model{events, posts} {
+		if err := model.Register(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record := func(name string) Hook {
+		return func(model *Model, object Object) error {
+			event := NewObject()
+			event.Object["Name"] = name
+			//The model is bound to the transaction of the change, if there is one.
+			if model.tx != nil {
+				_, _, err := model.tx.Model(events).AddNewRecord(event)
+				return err
+			}
+			_, _, err := events.AddNewRecord(event)
+			return err
+		}
+	}
+	//The Before hook runs before the validators, so it can fill in the Slug.
+	posts.BeforeCreate = func(model *Model, object Object) error {
+		if title, ok := object.Object["Title"].(string); ok {
+			object.Object["Slug"] = strings.ToLower(title)
+		}
+		return nil
+	}
+	posts.AfterCreate = record("created")
+	failed := errors.New("failed")
+	posts.BeforeDelete = func(model *Model, object Object) error {
+		if object.Object["Slug"] == "kept" {
+			return failed
+		}
+		return nil
+	}
+	posts.AfterDelete = record("deleted")
+
+	object := NewObject()
+	object.Object["Title"] = "Hello"
+	if _, _, err := posts.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := posts.GetRecord("Slug", "hello"); (err != nil) || (len(records) != 1) {
+		t.Errorf("GetRecord of the slug = %v, %v", records, err)
+	}
+	_, _, err := posts.AddNewRecord(NewObject())
+	if errs, ok := err.(ValidationErrors); !ok || !reflect.DeepEqual(errs["Title"], []string{"is required"}) {
+		t.Errorf("AddNewRecord without a Title : %v, want ValidationErrors", err)
+	}
+	//A Before hook error stops the change.
+	object.Object["Title"] = "Kept"
+	if _, _, err := posts.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := posts.DeleteRecord("Slug", "kept"); err != failed {
+		t.Errorf("DeleteRecord stopped by BeforeDelete : %v, want %v", err, failed)
+	}
+	if n := count(t, posts); n != 2 {
+		t.Errorf("%d posts after the stopped DeleteRecord, want 2", n)
+	}
+	if n := count(t, events); n != 2 {
+		t.Errorf("%d events, want 2", n)
+	}
+	//The After hooks run in the transaction of the change, which is rolled back with what they did.
+	posts.AfterCreate = func(model *Model, object Object) error {
+		if err := record("created")(model, object); err != nil {
+			return err
+		}
+		return failed
+	}
+	err = Transaction(func(tx *Tx) error {
+		object.Object["Title"] = "Rolled back"
+		_, _, err := tx.Model(posts).AddNewRecord(object)
+		return err
+	})
+	if err != failed {
+		t.Errorf("Transaction = %v, want %v", err, failed)
+	}
+	if (count(t, posts) != 2) || (count(t, events) != 2) {
+		t.Error("the post or the event of the failed AfterCreate hook was kept")
+	}
+}
+
+func TestSQLiteSoftDelete(t *testing.T) {
+	useSQLite(t)
+	notes := &Model{Name: "notes", PrimaryKey: "ID", SoftDelete: true, Timestamps: true, Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Body": {Type: TextField},
+	}}
+	if err := notes.Register(); err != nil {
+		t.Fatal(err)
+	}
+	object := NewObject()
+	object.Object["Body"] = "first"
+	if _, _, err := notes.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := object.Object[CreatedAtColumn]; ok {
+		t.Error("AddNewRecord set the timestamps in the object passed to it")
+	}
+	records, err := notes.GetRecord("ID", 1)
+	if (err != nil) || (len(records) != 1) {
+		t.Fatalf("GetRecord = %v, %v", records, err)
+	}
+	created, _ := records[0].Object[CreatedAtColumn].(time.Time)
+	if created.IsZero() || (records[0].Object[UpdatedAtColumn] != created) || (records[0].Object[DeletedAtColumn] != nil) {
+		t.Errorf("the timestamps of a new record are %v", records[0].Object)
+	}
+	update := NewObject()
+	update.Object["Body"] = "changed"
+	update.Object[UpdatedAtColumn] = created.Add(time.Hour)
+	if _, err := notes.UpdateRecord(update, "ID", 1); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := notes.Query().Where(UpdatedAtColumn + " >", created).All(); (err != nil) || (len(records) != 1) {
+		t.Errorf("query of the updated_at column = %v, %v", records, err)
+	}
+	if _, err := notes.DeleteRecord("ID", 1); err != nil {
+		t.Fatal(err)
+	}
+	//The record is kept, but left out of the reads and updates.
+	if records, err := notes.GetRecord("ID", 1); (err != nil) || (len(records) != 0) {
+		t.Errorf("GetRecord of a deleted record = %v, %v", records, err)
+	}
+	if n, err := notes.UpdateRecord(update, "ID", 1); (n != 0) || (err != ErrNotFound) {
+		t.Errorf("UpdateRecord of a deleted record = %d, %v, want ErrNotFound", n, err)
+	}
+	if n, err := notes.DeleteRecord("ID", 1); (n != 0) || (err != ErrNotFound) {
+		t.Errorf("DeleteRecord of a deleted record = %d, %v, want ErrNotFound", n, err)
+	}
+	if n, err := notes.Query().Count(); (n != 0) || (err != nil) {
+		t.Errorf("Count = %d, %v, want 0", n, err)
+	}
+	deleted, err := notes.Query().OnlyDeleted().All()
+	if (err != nil) || (len(deleted) != 1) || (deleted[0].Object["Body"] != "changed") || (deleted[0].Object[DeletedAtColumn] == nil) {
+		t.Errorf("OnlyDeleted = %v, %v", deleted, err)
+	}
+	if _, err := notes.ForceDeleteRecord("ID", 1); err != nil {
+		t.Fatal(err)
+	}
+	if count(t, notes) != 0 {
+		t.Error("the record is still there after ForceDeleteRecord")
+	}
+}
+
+func TestSQLitePaginate(t *testing.T) {
+	useSQLite(t)
+	posts := &Model{Name: "posts", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"Draft": {Type: Boolean},
+	}}
+	if err := posts.Register(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 7; i++ {
+		object := NewObject()
+		object.Object["Draft"] = i == 4
+		if _, _, err := posts.AddNewRecord(object); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(objects Objects) []interface{} {
+		var values []interface{}
+		for _, object := range objects {
+			values = append(values, object.Object["ID"])
+		}
+		return values
+	}
+	page, err := Paginate(posts.Query().OrderBy("ID"), 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids(page.Items), []interface{}{4, 5, 6}) || (page.Total != 7) || (page.Pages != 3) || !page.HasNext || !page.HasPrev {
+		t.Errorf("Paginate page 2 = %+v", page)
+	}
+	if page, err = Paginate(posts.Query().OrderBy("ID"), 0, 3); (err != nil) || (page.Page != 1) || page.HasPrev {
+		t.Errorf("Paginate page 0 = %+v, %v, want the first page", page, err)
+	}
+	if page, err = Paginate(posts.Query().OrderBy("ID"), 4, 3); (err != nil) || (len(page.Items) != 0) || page.HasNext {
+		t.Errorf("Paginate after the last page = %+v, %v", page, err)
+	}
+	if _, err = Paginate(posts.Query(), 1, 0); err == nil {
+		t.Error("Paginate of 0 rows per page : no error")
+	}
+
+	//The OR condition of the query doesn't bypass the cursor.
+	query := posts.Query().Where("Draft", false).OrWhere("ID", 4)
+	var seen []interface{}
+	var cursor interface{}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("PaginateAfter doesn't stop")
+		}
+		page, err := PaginateAfter(query, "-ID", cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.HasPrev != (cursor != nil) {
+			t.Errorf("HasPrev = %v with the cursor %v", page.HasPrev, cursor)
+		}
+		seen = append(seen, ids(page.Items)...)
+		if !page.HasNext {
+			break
+		}
+		cursor = page.Cursor
+	}
+	if !reflect.DeepEqual(seen, []interface{}{7, 6, 5, 4, 3, 2, 1}) {
+		t.Errorf("PaginateAfter walked %v", seen)
+	}
+}
+
+func TestSQLiteBulk(t *testing.T) {
+	useSQLite(t)
+	items := &Model{Name: "items", PrimaryKey: "ID", Fields: Fields{
+		"ID":    {Type: Integer, AutoIncrement: true},
+		"SKU":   {Type: CharField, Unique: true},
+		"Shop":  {Type: CharField, Default: "main"},
+		"Stock": {Type: Integer, Validators: []Validator{Range(0, 1000)}},
+	}}
+	if err := items.Register(); err != nil {
+		t.Fatal(err)
+	}
+	//3 columns a row make 333 rows a statement, so the 1000 rows take 4 of them.
+	objects := make(Objects, 1000)
+	for i := range objects {
+		objects[i] = NewObject()
+		objects[i].Object["SKU"] = fmt.Sprintf("sku-%04d", i)
+		objects[i].Object["Stock"] = i % 10
+		if i%2 == 0 {
+			objects[i].Object["Shop"] = "outlet"
+		}
+	}
+	if err := items.BulkInsert(objects); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, items); n != 1000 {
+		t.Errorf("%d items after BulkInsert, want 1000", n)
+	}
+	if n, err := items.Query().Where("Shop", "main").Count(); (n != 500) || (err != nil) {
+		t.Errorf("%d, %v items with the default Shop, want 500", n, err)
+	}
+	//No record is inserted if one of the objects is not valid.
+	invalid := Objects{NewObject(), NewObject()}
+	invalid[0].Object["SKU"], invalid[0].Object["Stock"] = "new", 1
+	invalid[1].Object["SKU"], invalid[1].Object["Stock"] = "bad", -1
+	if _, ok := items.BulkInsert(invalid).(ValidationErrors); !ok {
+		t.Error("BulkInsert of an invalid object : no ValidationErrors")
+	}
+	if n := count(t, items); n != 1000 {
+		t.Errorf("%d items after the failed BulkInsert, want 1000", n)
+	}
+
+	upsert := NewObject()
+	upsert.Object["SKU"], upsert.Object["Stock"] = "sku-0001", 999
+	if err := items.Upsert(upsert, "SKU"); err != nil {
+		t.Fatal(err)
+	}
+	upsert.Object["SKU"] = "sku-new"
+	if err := items.Upsert(upsert, "SKU"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, items); n != 1001 {
+		t.Errorf("%d items after the Upserts, want 1001", n)
+	}
+	if updated, err := items.Query().Where("SKU", "sku-0001").First(); (err != nil) || (updated.Object["Stock"] != 999) || (updated.Object["Shop"] != "main") {
+		t.Errorf("the upserted item is %v, %v", updated.Object, err)
+	}
+
+	if sum, err := items.Query().Where("Shop", "outlet").Sum("Stock"); (sum != 2000) || (err != nil) {
+		t.Errorf("Sum = %v, %v, want 2000", sum, err)
+	}
+	if avg, err := items.Query().Where("SKU IN", []string{"sku-0002", "sku-0003"}).Avg("Stock"); (avg != 2.5) || (err != nil) {
+		t.Errorf("Avg = %v, %v, want 2.5", avg, err)
+	}
+	if max, err := items.Query().Where("Shop", "main").Max("Stock"); (max != 999) || (err != nil) {
+		t.Errorf("Max = %v, %v, want 999", max, err)
+	}
+	if sum, err := items.Query().Where("SKU", "none").Sum("Stock"); (sum != 0) || (err != nil) {
+		t.Errorf("Sum of no rows = %v, %v, want 0", sum, err)
+	}
+	groups, err := items.Query().Select("Shop", "COUNT(*) AS Items").Where("Stock <", 100).GroupBy("Shop").
+		Having("COUNT(*) >", 499).OrderBy("Shop").All()
+	if (err != nil) || (len(groups) != 1) || (groups[0].Object["Shop"] != "outlet") || (groups[0].Object["Items"] != 500) {
+		t.Errorf("GroupBy = %v, %v, want the 500 outlet items", groups, err)
+	}
+
+	update := NewObject()
+	update.Object["Stock"] = 0
+	if n, err := items.UpdateWhere(update, "Shop", "outlet"); (n != 500) || (err != nil) {
+		t.Errorf("UpdateWhere = %d, %v, want 500", n, err)
+	}
+	if n, err := items.DeleteWhere("Stock", 0); (n != 500) || (err != nil) {
+		t.Errorf("DeleteWhere = %d, %v, want 500", n, err)
+	}
+	if n := count(t, items); n != 501 {
+		t.Errorf("%d items after DeleteWhere, want 501", n)
+	}
+}
+
+func TestSQLiteContext(t *testing.T) {
+	useSQLite(t)
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField},
+	}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	object := NewObject()
+	object.Object["Name"] = "ann"
+	if _, _, err := users.AddNewRecordContext(cancelled, object); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddNewRecordContext with a cancelled context : %v, want context.Canceled", err)
+	}
+	if _, err := users.GetRecordContext(expired, "ID", 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetRecordContext with an expired context : %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := users.Query().WithContext(expired).All(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Query.WithContext with an expired context : %v, want context.DeadlineExceeded", err)
+	}
+	err := TransactionContext(cancelled, func(tx *Tx) error {
+		_, _, err := tx.Model(users).AddNewRecord(object)
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TransactionContext with a cancelled context : %v, want context.Canceled", err)
+	}
+	//The model the context was given to is left as it is.
+	if _, _, err := users.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, users); n != 1 {
+		t.Errorf("%d users, want only the one added without a context", n)
+	}
+}
+
+func TestSQLiteQueryTimeout(t *testing.T) {
+	useSQLite(t)
+	previous := db
+	err := SetDatabaseConfig(Database{Driver: "sqlite", Database: filepath.Join(t.TempDir(), "timeout.db"), QueryTimeout: "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.Close()
+	t.Cleanup(func() { queryTimeout = 0 })
+	if queryTimeout != 100*time.Millisecond {
+		t.Fatalf("queryTimeout = %v, want 100ms", queryTimeout)
+	}
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{"ID": {Type: Integer, AutoIncrement: true}}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	//The query never ends by itself.
+	start := time.Now()
+	_, err = users.DoQueryArgs("WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x+1 FROM n) SELECT COUNT(*) AS ID FROM n")
+	if err == nil {
+		t.Fatal("the endless query returned no error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the endless query was stopped after %v", elapsed)
+	}
+	if err := SetDatabaseConfig(Database{Driver: "sqlite", Database: "x.db", QueryTimeout: "soon"}); err == nil {
+		t.Error("SetDatabaseConfig of an invalid QueryTimeout : no error")
+	}
+}
+
+//events is a QueryLogger keeping the events it is given.
+type events struct {
+	list []QueryEvent
+}
+
+func (e *events) LogQuery(ctx context.Context, event QueryEvent) {
+	e.list = append(e.list, event)
+}
+
+func TestSQLiteQueryLogger(t *testing.T) {
+	useSQLite(t)
+	logged := &events{}
+	SetQueryLogger(logged)
+	t.Cleanup(func() { SetQueryLogger(DefaultLogger) })
+	users := &Model{Name: "users", PrimaryKey: "ID", Fields: Fields{
+		"ID":   {Type: Integer, AutoIncrement: true},
+		"Name": {Type: CharField, Unique: true},
+	}}
+	if err := users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	object := NewObject()
+	object.Object["Name"] = "ann"
+	if _, _, err := users.AddNewRecord(object); err != nil {
+		t.Fatal(err)
+	}
+	ctx := CountQueries(context.Background())
+	logged.list = nil
+	if _, err := users.Query().WithContext(ctx).Where("Name IN", []string{"ann", "bob"}).All(); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := users.WithContext(ctx).AddNewRecord(object)
+	if len(logged.list) < 2 {
+		t.Fatalf("%d statements logged, want at least 2", len(logged.list))
+	}
+	read, insert := logged.list[0], logged.list[len(logged.list)-1]
+	if (read.Query != `SELECT * FROM "users" WHERE "Name" IN (?,?)`) || !reflect.DeepEqual(read.Args, []interface{}{"ann", "bob"}) ||
+		(read.RowsAffected != 1) || (read.Err != nil) {
+		t.Errorf("the query was logged as %+v", read)
+	}
+	if !strings.HasPrefix(insert.Query, `INSERT INTO "users"`) || (insert.Err == nil) || !errors.Is(err, ErrDuplicate) {
+		t.Errorf("the failed insert was logged as %+v", insert)
+	}
+	if n := QueryCount(ctx); n != len(logged.list) {
+		t.Errorf("QueryCount = %d, want the %d statements run with the context", n, len(logged.list))
+	}
+	if n := QueryCount(context.Background()); n != 0 {
+		t.Errorf("QueryCount of a context not counting = %d", n)
+	}
+
+	//With RedactQueryArgs the values are left out.
+	previous := db
+	err = SetDatabaseConfig(Database{Driver: "sqlite", Database: filepath.Join(t.TempDir(), "redacted.db"), RedactQueryArgs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous.Close()
+	t.Cleanup(func() { redactArgs = false })
+	logged.list = nil
+	if _, err := users.DoQueryArgs(`SELECT ? AS "Name"`, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if (len(logged.list) != 1) || !reflect.DeepEqual(logged.list[0].Args, []interface{}{"?"}) {
+		t.Errorf("the redacted query was logged as %+v", logged.list)
+	}
+}
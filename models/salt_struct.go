@@ -0,0 +1,380 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//FromStruct derives a Model from a struct, so the models can be declared as Go types :
+//
+//    type User struct {
+//        ID    int    `salt:"pk,autoinc"`
+//        Email string `salt:"unique,notnull,size:64"`
+//        Bio   string `salt:"text"`
+//        Age   int
+//        Admin bool   `salt:"column:is_admin"`
+//        Cache string `salt:"-"`
+//    }
+//
+//    var Users, err = models.FromStruct(&User{})
+//
+//Every exported field is a column, named after the field unless the tag has a column option. The options of the salt
+//tag are :
+//
+//    pk          -  the primary key (a field named ID is the primary key if no field has pk)
+//    autoinc     -  AutoIncrement
+//    unique      -  Unique
+//    notnull     -  NotNull
+//    index       -  Index
+//    size:N      -  MaxLength of a string field
+//    text        -  a TextField instead of a CharField for a string field
+//    type:x      -  the Type : string, text, int, float, bool, datetime, date, time, decimal, bigint, json, blob or uuid
+//    precision:N -  Precision of a decimal field
+//    scale:N     -  Scale of a decimal field
+//    column:x    -  the column name
+//    -           -  not a column
+//
+//string fields are CharFields, integer fields Integers (BigIntegers for int64, uint, uint32 and uint64, which don't
+//fit in 32 bits), float fields Floats and bool fields Booleans, as are their nullable versions from database/sql
+//(sql.NullString, sql.NullInt64 ...). time.Time and sql.NullTime fields are
+//DateTimes, json.RawMessage fields JSON and []byte fields Blobs. The model is
+//named after the struct type, or by its TableName() string method if it has one.
+//
+//Rows are scanned into the struct with Query.Into and a struct is turned into an Object with ToObject.
+func FromStruct(v interface{}) (Model, error) {
+	t, err := structType(v)
+	if err != nil {
+		return Model{}, err
+	}
+	columns, err := structColumns(t)
+	if err != nil {
+		return Model{}, err
+	}
+	model := Model{Name : t.Name(), Fields : make(Fields)}
+	if named, ok := v.(interface{ TableName() string }); ok {
+		model.Name = named.TableName()
+	}
+	for _, column := range columns {
+		model.Fields[column.name] = column.field
+		if column.pk {
+			if model.PrimaryKey != "" {
+				return Model{}, errors.New("Error : " + t.Name() + " has more than one primary key")
+			}
+			model.PrimaryKey = column.name
+		}
+	}
+	if _, ok := model.Fields["ID"]; ok && (model.PrimaryKey == "") {
+		model.PrimaryKey = "ID"
+	}
+	return model, nil
+}
+
+//ToObject returns an Object with the columns of a struct (see FromStruct), to be used with AddNewRecord or
+//UpdateRecord. An auto incremented field with its zero value is left out, so the database numbers it.
+func ToObject(v interface{}) (Object, error) {
+	t, err := structType(v)
+	if err != nil {
+		return NewObject(), err
+	}
+	columns, err := structColumns(t)
+	if err != nil {
+		return NewObject(), err
+	}
+	value := reflect.Indirect(reflect.ValueOf(v))
+	object := NewObject()
+	for _, column := range columns {
+		field := value.FieldByIndex(column.index)
+		if column.field.AutoIncrement && field.IsZero() {
+			continue
+		}
+		object.Object[column.name] = field.Interface()
+	}
+	return object, nil
+}
+
+//Into runs the query and scans the rows into dest, which is a pointer to a slice of structs (or of pointers to
+//structs) declared as described in FromStruct, or a pointer to a struct for the first row (ErrNotFound is returned
+//if there is none) :
+//
+//    var users []User
+//    err := Users.Query().Where("Age >", 18).Into(&users)
+//
+//Columns without a matching struct field are ignored and NULL values are scanned as the zero value of the field,
+//unless the field type is a sql.Scanner (like sql.NullString), which is then given the value as such.
+func (q *Query) Into(dest interface{}) (error) {
+	target := reflect.ValueOf(dest)
+	if (target.Kind() != reflect.Ptr) || target.IsNil() {
+		return errors.New("Error : Into needs a pointer to a slice or a struct")
+	}
+	target = target.Elem()
+	single := target.Kind() == reflect.Struct
+	elem := target.Type()
+	if !single {
+		if target.Kind() != reflect.Slice {
+			return errors.New("Error : Into needs a pointer to a slice or a struct")
+		}
+		elem = target.Type().Elem()
+	}
+	pointers := elem.Kind() == reflect.Ptr
+	if pointers {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return errors.New("Error : Into needs a pointer to a slice or a struct")
+	}
+	columns, err := structColumns(elem)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]structColumn)
+	for _, column := range columns {
+		byName[column.name] = column
+	}
+
+	query := *q
+	if single {
+		query.limit = 1
+	}
+	stmt, args, err := query.SQL()
+	if err != nil {
+		return err
+	}
+	rows, err := q.model.query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	rowsRead := reflect.MakeSlice(reflect.SliceOf(elem), 0, 0)
+	for rows.Next() {
+		row := reflect.New(elem).Elem()
+		targets := make([]interface{}, len(names))
+		setters := make([]func() error, 0, len(names))
+		for i, name := range names {
+			column, ok := byName[name]
+			if !ok {
+				targets[i] = new(interface{})
+				continue
+			}
+			targets[i], setters = scanTarget(row.FieldByIndex(column.index), column.field, setters)
+		}
+		err = rows.Scan(targets...)
+		if err != nil {
+			return err
+		}
+		for _, set := range setters {
+			err = set()
+			if err != nil {
+				return err
+			}
+		}
+		rowsRead = reflect.Append(rowsRead, row)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if single {
+		if rowsRead.Len() == 0 {
+			return ErrNotFound
+		}
+		target.Set(rowsRead.Index(0))
+		return nil
+	}
+	result := reflect.MakeSlice(target.Type(), rowsRead.Len(), rowsRead.Len())
+	for i := 0; i < rowsRead.Len(); i++ {
+		if pointers {
+			result.Index(i).Set(rowsRead.Index(i).Addr())
+		} else {
+			result.Index(i).Set(rowsRead.Index(i))
+		}
+	}
+	target.Set(result)
+	return nil
+}
+
+//scanTarget returns what a column is scanned into for the struct field and adds the function setting the field
+//from it, if it isn't scanned into the field itself, to setters.
+func scanTarget(field reflect.Value, column Field, setters []func() error) (interface{}, []func() error) {
+	if _, ok := field.Addr().Interface().(sql.Scanner); ok {
+		return field.Addr().Interface(), setters
+	}
+	if _, mapped := fieldTypes[field.Type()]; !mapped {
+		switch field.Kind() {
+		case reflect.String:
+			var v sql.NullString
+			return &v, append(setters, func() error { field.SetString(v.String); return nil })
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var v sql.NullInt64
+			return &v, append(setters, func() error { field.SetInt(v.Int64); return nil })
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			var v sql.NullInt64
+			return &v, append(setters, func() error { field.SetUint(uint64(v.Int64)); return nil })
+		case reflect.Float32, reflect.Float64:
+			var v sql.NullFloat64
+			return &v, append(setters, func() error { field.SetFloat(v.Float64); return nil })
+		case reflect.Bool:
+			var v sql.NullBool
+			return &v, append(setters, func() error { field.SetBool(v.Bool); return nil })
+		}
+	}
+	//The other types (time.Time, []byte ...) are decoded like in the Objects.
+	var raw interface{}
+	return &raw, append(setters, func() error {
+		value, err := decode(column, raw)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		v := reflect.ValueOf(value)
+		if !v.Type().ConvertibleTo(field.Type()) {
+			return errors.New("Error : A " + v.Type().String() + " value can't be scanned into a " + field.Type().String() + " field")
+		}
+		field.Set(v.Convert(field.Type()))
+		return nil
+	})
+}
+
+//The struct field types which aren't mapped by their kind, with their Type.
+var fieldTypes = map[reflect.Type]Type{
+	reflect.TypeOf(sql.NullString{})    : CharField,
+	reflect.TypeOf(sql.NullInt64{})     : BigInteger,
+	reflect.TypeOf(sql.NullInt32{})     : Integer,
+	reflect.TypeOf(sql.NullInt16{})     : Integer,
+	reflect.TypeOf(sql.NullFloat64{})   : Float,
+	reflect.TypeOf(sql.NullBool{})      : Boolean,
+	reflect.TypeOf(sql.NullTime{})      : DateTime,
+	reflect.TypeOf(time.Time{})         : DateTime,
+	reflect.TypeOf(json.RawMessage{})   : JSON,
+	reflect.TypeOf([]byte{})            : Blob,
+}
+
+//The types which can be set with the type option of the salt tag.
+var tagTypes = map[string]Type{
+	"string" : CharField, "text" : TextField, "int" : Integer, "float" : Float, "bool" : Boolean,
+	"datetime" : DateTime, "date" : Date, "time" : Time, "decimal" : Decimal, "bigint" : BigInteger,
+	"json" : JSON, "blob" : Blob, "uuid" : UUID,
+}
+
+//integerType returns the Type of the columns of an integer kind and whether the kind is one : BigInteger for the
+//kinds whose values don't all fit in a 32 bits INT, Integer for the others.
+func integerType(kind reflect.Kind) (Type, bool) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return Integer, true
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return BigInteger, true
+	}
+	return "", false
+}
+
+//structColumn is a struct field which is a column.
+type structColumn struct {
+	name  string
+	index []int
+	field Field
+	pk    bool
+}
+
+//structType returns the struct type of v, a struct or a pointer to one.
+func structType(v interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	if (t != nil) && (t.Kind() == reflect.Ptr) {
+		t = t.Elem()
+	}
+	if (t == nil) || (t.Kind() != reflect.Struct) {
+		return nil, errors.New("Error : A struct or a pointer to a struct is needed for a model")
+	}
+	return t, nil
+}
+
+//structColumns returns the columns of the struct type, read from its fields and their salt tags.
+func structColumns(t reflect.Type) ([]structColumn, error) {
+	var columns []structColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("salt")
+		if (f.PkgPath != "") || (tag == "-") {
+			continue
+		}
+		column := structColumn{name : f.Name, index : f.Index}
+		integer, isInteger := integerType(f.Type.Kind())
+		switch fieldType, mapped := fieldTypes[f.Type]; {
+		case mapped:
+			column.field.Type = fieldType
+		case f.Type.Kind() == reflect.String:
+			column.field.Type = CharField
+		case isInteger:
+			column.field.Type = integer
+		case (f.Type.Kind() == reflect.Float32) || (f.Type.Kind() == reflect.Float64):
+			column.field.Type = Float
+		case f.Type.Kind() == reflect.Bool:
+			column.field.Type = Boolean
+		default:
+			return nil, errors.New("Error : The type of " + t.Name() + "." + f.Name + " can't be a column")
+		}
+		for _, option := range strings.Split(tag, ",") {
+			option = strings.TrimSpace(option)
+			key, value := option, ""
+			if sep := strings.Index(option, ":"); sep >= 0 {
+				key, value = option[:sep], option[sep+1:]
+			}
+			switch key {
+			case "":
+			case "pk":
+				column.pk = true
+			case "autoinc":
+				column.field.AutoIncrement = true
+			case "unique":
+				column.field.Unique = true
+			case "notnull":
+				column.field.NotNull = true
+			case "index":
+				column.field.Index = true
+			case "text":
+				column.field.Type = TextField
+			case "type":
+				fieldType, ok := tagTypes[value]
+				if !ok {
+					return nil, errors.New("Error : Unknown type " + value + " in the salt tag of " + t.Name() + "." + f.Name)
+				}
+				column.field.Type = fieldType
+			case "precision", "scale":
+				digits, err := strconv.Atoi(value)
+				if (err != nil) || (digits < 0) {
+					return nil, errors.New("Error : Wrong " + key + " in the salt tag of " + t.Name() + "." + f.Name)
+				}
+				if key == "precision" {
+					column.field.Precision = digits
+				} else {
+					column.field.Scale = digits
+				}
+			case "size":
+				size, err := strconv.Atoi(value)
+				if (err != nil) || (size <= 0) {
+					return nil, errors.New("Error : Wrong size in the salt tag of " + t.Name() + "." + f.Name)
+				}
+				column.field.MaxLength = size
+			case "column":
+				if value == "" {
+					return nil, errors.New("Error : Empty column name in the salt tag of " + t.Name() + "." + f.Name)
+				}
+				column.name = value
+			default:
+				return nil, errors.New("Error : Unknown option " + option + " in the salt tag of " + t.Name() + "." + f.Name)
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStructColumns(t *testing.T) {
+	type account struct {
+		ID       int64 `salt:"pk,autoinc"`
+		Email    string `salt:"unique,notnull,size:120"`
+		Bio      string `salt:"text"`
+		Age      int
+		Small    int16
+		Tiny     uint8
+		Count    uint32
+		Big      uint64
+		Visits   sql.NullInt64
+		Score    float32
+		Admin    bool
+		Joined   time.Time `salt:"index"`
+		Born     time.Time `salt:"type:date"`
+		Balance  string `salt:"type:decimal,precision:12,scale:2"`
+		Settings json.RawMessage
+		Avatar   []byte
+		Nick     sql.NullString `salt:"column:nickname"`
+		Skipped  string `salt:"-"`
+		private  string
+	}
+	want := []structColumn{
+		{name: "ID", index: []int{0}, field: Field{Type: BigInteger, AutoIncrement: true}, pk: true},
+		{name: "Email", index: []int{1}, field: Field{Type: CharField, Unique: true, NotNull: true, MaxLength: 120}},
+		{name: "Bio", index: []int{2}, field: Field{Type: TextField}},
+		{name: "Age", index: []int{3}, field: Field{Type: Integer}},
+		{name: "Small", index: []int{4}, field: Field{Type: Integer}},
+		{name: "Tiny", index: []int{5}, field: Field{Type: Integer}},
+		{name: "Count", index: []int{6}, field: Field{Type: BigInteger}},
+		{name: "Big", index: []int{7}, field: Field{Type: BigInteger}},
+		{name: "Visits", index: []int{8}, field: Field{Type: BigInteger}},
+		{name: "Score", index: []int{9}, field: Field{Type: Float}},
+		{name: "Admin", index: []int{10}, field: Field{Type: Boolean}},
+		{name: "Joined", index: []int{11}, field: Field{Type: DateTime, Index: true}},
+		{name: "Born", index: []int{12}, field: Field{Type: Date}},
+		{name: "Balance", index: []int{13}, field: Field{Type: Decimal, Precision: 12, Scale: 2}},
+		{name: "Settings", index: []int{14}, field: Field{Type: JSON}},
+		{name: "Avatar", index: []int{15}, field: Field{Type: Blob}},
+		{name: "nickname", index: []int{16}, field: Field{Type: CharField}},
+	}
+	got, err := structColumns(reflect.TypeOf(account{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("structColumns returned %d columns, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("column %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStructColumnsErrors(t *testing.T) {
+	tests := []interface{}{
+		struct{ Tags map[string]string }{},
+		struct{ Tags []string }{},
+		struct {
+			Name string `salt:"primary"`
+		}{},
+		struct {
+			Name string `salt:"type:varchar"`
+		}{},
+		struct {
+			Name string `salt:"size:0"`
+		}{},
+		struct {
+			Price string `salt:"type:decimal,scale:-1"`
+		}{},
+		struct {
+			Name string `salt:"column:"`
+		}{},
+	}
+	for _, test := range tests {
+		if _, err := structColumns(reflect.TypeOf(test)); err == nil {
+			t.Errorf("structColumns(%T) : no error", test)
+		}
+	}
+}
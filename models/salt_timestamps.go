@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+//The columns maintained by the models with Timestamps (created_at, updated_at) and SoftDelete (deleted_at). They are
+//DateTime columns added to the table, which can be read and queried like the fields.
+const(
+	CreatedAtColumn = "created_at"
+	UpdatedAtColumn = "updated_at"
+	DeletedAtColumn = "deleted_at"
+)
+
+//managedFields returns the fields of the columns maintained by the models layer for the options of the model.
+func (model *Model) managedFields() (Fields) {
+	fields := make(Fields)
+	if model.Timestamps {
+		fields[CreatedAtColumn] = Field{Type : DateTime}
+		fields[UpdatedAtColumn] = Field{Type : DateTime}
+	}
+	if model.SoftDelete {
+		fields[DeletedAtColumn] = Field{Type : DateTime}
+	}
+	return fields
+}
+
+//now returns the time written to the timestamp columns.
+func now() (time.Time) {
+	return time.Now().UTC()
+}
+
+//stamped returns a copy of the object with the timestamp columns set for a new record (creating) or an updated one.
+//The values already in the object are kept.
+func (model *Model) stamped(object Object, creating bool) (Object) {
+	if !model.Timestamps {
+		return object
+	}
+	copied := NewObject()
+	for name, value := range object.Object {
+		copied.Object[name] = value
+	}
+	t := now()
+	if _, ok := copied.Object[CreatedAtColumn]; creating && !ok {
+		copied.Object[CreatedAtColumn] = t
+	}
+	if _, ok := copied.Object[UpdatedAtColumn]; !ok {
+		copied.Object[UpdatedAtColumn] = t
+	}
+	return copied
+}
+
+//notDeleted returns the condition leaving out the soft deleted rows, empty if the model doesn't soft delete.
+func (model *Model) notDeleted() (string) {
+	if !model.SoftDelete {
+		return ""
+	}
+	return quote(DeletedAtColumn) + " IS NULL"
+}
+
+//WithDeleted includes the soft deleted rows in the query, which leaves them out by default.
+func (q *Query) WithDeleted() (*Query) {
+	q.withDeleted, q.onlyDeleted = true, false
+	return q
+}
+
+//OnlyDeleted restricts the query to the soft deleted rows.
+func (q *Query) OnlyDeleted() (*Query) {
+	q.withDeleted, q.onlyDeleted = false, true
+	return q
+}
+
+//deletedFilter returns the condition on deleted_at the query adds to its own, empty if there is none.
+func (q *Query) deletedFilter() (string) {
+	switch {
+	case !q.model.SoftDelete, q.withDeleted:
+		return ""
+	case q.onlyDeleted:
+		return quote(DeletedAtColumn) + " IS NOT NULL"
+	}
+	return q.model.notDeleted()
+}
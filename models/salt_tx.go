@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+//executor is what the queries of the models run on : the connection pool (*sql.DB) or a transaction (*sql.Tx).
+type executor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+//Tx is a database transaction. The models bound to it with Model run their queries in the transaction.
+type Tx struct {
+	tx    *sql.Tx
+	ctx   context.Context
+	depth int
+}
+
+//Transaction runs fn in a transaction. The transaction is committed if fn returns nil and rolled back if it returns an
+//error (which Transaction returns) or panics (the panic goes on after the rollback) :
+//
+//    err := models.Transaction(func(tx *models.Tx) error {
+//        _, err := tx.Model(&Accounts).UpdateRecord(debit, "ID", from)
+//        if err != nil {
+//            return err
+//        }
+//        _, err = tx.Model(&Accounts).UpdateRecord(credit, "ID", to)
+//        return err
+//    })
+func Transaction(fn func(tx *Tx) error) (err error) {
+	return TransactionContext(context.Background(), fn)
+}
+
+//TransactionContext is Transaction with a context : the transaction is rolled back if the context is cancelled
+//before it is committed, and the queries of the models bound to it run with the context.
+func TransactionContext(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	db, err := connection()
+	if err != nil {
+		return err
+	}
+	return transaction(ctx, db, fn)
+}
+
+//beginner is what transactions are begun on : the connection pool (*sql.DB) or a single connection (*sql.Conn).
+type beginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+//transaction runs fn in a transaction begun on b, like TransactionContext.
+func transaction(ctx context.Context, b beginner, fn func(tx *Tx) error) (err error) {
+	sqltx, err := b.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{tx : sqltx, ctx : ctx}
+	defer func() {
+		if r := recover(); r != nil {
+			sqltx.Rollback()
+			panic(r)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		if rollbackErr := sqltx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed : %v)", err, rollbackErr)
+		}
+		return err
+	}
+	return sqltx.Commit()
+}
+
+//Transaction runs fn in a nested transaction, using a savepoint : if fn returns an error or panics, only the changes
+//made in fn are rolled back and the outer transaction goes on. An error is returned if the database doesn't support
+//savepoints.
+func (tx *Tx) Transaction(fn func(tx *Tx) error) (err error) {
+	if !dialect.Savepoints() {
+		return errors.New("Error : The database doesn't support savepoints, transactions can't be nested")
+	}
+	tx.depth++
+	defer func() { tx.depth-- }()
+	savepoint := "salt_savepoint_" + strconv.Itoa(tx.depth)
+	_, err = tx.Exec("SAVEPOINT " + savepoint)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			//The panic goes on, a failed rollback is only logged.
+			tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+			panic(r)
+		}
+	}()
+	err = fn(tx)
+	if err != nil {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed : %v)", err, rollbackErr)
+		}
+		return err
+	}
+	_, err = tx.Exec("RELEASE SAVEPOINT " + savepoint)
+	return err
+}
+
+//Model returns a copy of the model bound to the transaction : all its methods (AddNewRecord, UpdateRecord,
+//DeleteRecord, GetRecord, DoQuery ...) run in the transaction. The model passed is left as it is.
+func (tx *Tx) Model(model *Model) (*Model) {
+	bound := *model
+	bound.tx = tx
+	return &bound
+}
+
+//Exec runs a statement written with ? placeholders in the transaction. Constraint violations return a DatabaseError,
+//like AddNewRecord.
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	query = rebind(query)
+	start := time.Now()
+	result, err := tx.tx.ExecContext(tx.ctx, query, args...)
+	logExec(tx.ctx, query, args, start, result, err)
+	return result, translate(err)
+}
+
+//Query runs a query written with ? placeholders in the transaction.
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	query = rebind(query)
+	start := time.Now()
+	result, err := tx.tx.QueryContext(tx.ctx, query, args...)
+	logQuery(tx.ctx, query, args, time.Since(start), -1, err)
+	return result, translate(err)
+}
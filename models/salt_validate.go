@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//Hook is a function called by AddNewRecord, UpdateRecord and DeleteRecord before or after they change a record (see
+//the hooks of Model). The model passed is the one the method was called on, so the queries a hook makes through it
+//run in the same transaction. An error returned by a Before hook stops the change and is returned ; one returned by
+//an After hook is returned once the change is made (so a Transaction can roll it back).
+type Hook func(model *Model, object Object) (error)
+
+//Validator checks a value written to a field and returns an error describing what is wrong with it. The value is nil
+//when the field is missing from a new record ; only Required rejects it, the other validators let nil through.
+//Any function with this signature can be used as a custom validator :
+//
+//    "Email" : models.Field{Type : models.CharField, Validators : []models.Validator{
+//        models.Required(), models.MaxLength(64), models.Match(`^[^@]+@[^@]+$`),
+//    }}
+type Validator func(value interface{}) (error)
+
+//ValidationErrors is the error returned when the values of a record don't pass the validators of their fields. It
+//maps the field names to the messages of the failed validators, so a view can show them next to the form fields :
+//
+//    _, _, err := Users.AddNewRecord(object)
+//    if errs, ok := err.(models.ValidationErrors); ok {
+//        // errs["Email"] : []string{"is required"}
+//    }
+type ValidationErrors map[string][]string
+
+//Error lists the failed fields in order with their messages.
+func (errs ValidationErrors) Error() (string) {
+	var fields []string
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	var messages []string
+	for _, field := range fields {
+		messages = append(messages, field + " " + strings.Join(errs[field], ", "))
+	}
+	return "Error : Validation failed : " + strings.Join(messages, "; ")
+}
+
+//Required rejects missing, NULL and empty values.
+func Required() (Validator) {
+	return func(value interface{}) error {
+		if (value == nil) || (value == "") {
+			return errors.New("is required")
+		}
+		if v := reflect.ValueOf(value); (v.Kind() == reflect.Slice) && (v.Len() == 0) {
+			return errors.New("is required")
+		}
+		return nil
+	}
+}
+
+//MinLength rejects strings (and byte slices) shorter than n characters.
+func MinLength(n int) (Validator) {
+	return func(value interface{}) error {
+		if length, ok := valueLength(value); ok && (length < n) {
+			return errors.New("must be at least " + strconv.Itoa(n) + " characters long")
+		}
+		return nil
+	}
+}
+
+//MaxLength rejects strings (and byte slices) longer than n characters.
+func MaxLength(n int) (Validator) {
+	return func(value interface{}) error {
+		if length, ok := valueLength(value); ok && (length > n) {
+			return errors.New("must be at most " + strconv.Itoa(n) + " characters long")
+		}
+		return nil
+	}
+}
+
+//Match rejects the strings not matching the regular expression. It panics if the expression doesn't compile, like
+//regexp.MustCompile, as the validators are declared with the models.
+func Match(pattern string) (Validator) {
+	expression := regexp.MustCompile(pattern)
+	return func(value interface{}) error {
+		str, ok := value.(string)
+		if ok && !expression.MatchString(str) {
+			return errors.New("is not in the expected format")
+		}
+		return nil
+	}
+}
+
+//Range rejects the numbers lower than min or greater than max, and the values which are not numbers.
+func Range(min float64, max float64) (Validator) {
+	return func(value interface{}) error {
+		if value == nil {
+			return nil
+		}
+		number, ok := valueNumber(value)
+		if !ok {
+			return errors.New("must be a number")
+		}
+		if (number < min) || (number > max) {
+			return errors.New("must be between " + strconv.FormatFloat(min, 'f', -1, 64) + " and " + strconv.FormatFloat(max, 'f', -1, 64))
+		}
+		return nil
+	}
+}
+
+//valueLength returns the number of characters of a string value, or of bytes of a byte slice.
+func valueLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		return utf8.RuneCountInString(v), true
+	case []byte:
+		return len(v), true
+	}
+	return 0, false
+}
+
+//valueNumber returns the value of a number, or of a string holding one (like the Decimal values).
+func valueNumber(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		number, err := strconv.ParseFloat(v.String(), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+//Validate checks the object as a new record against the validators of the fields, the missing fields included. It
+//returns ValidationErrors if some values don't pass, nil otherwise.
+func (model *Model) Validate(object Object) (error) {
+	return model.validate(object, true)
+}
+
+//validate checks the values of the object against the validators of their fields. The missing fields are checked
+//too (as nil) when all is set, that is for a new record.
+func (model *Model) validate(object Object, all bool) (error) {
+	errs := make(ValidationErrors)
+	for name, field := range model.Fields {
+		value, ok := object.Object[name]
+		if !ok && !all {
+			continue
+		}
+		for _, validator := range field.Validators {
+			if err := validator(value); err != nil {
+				errs[name] = append(errs[name], err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//runHook calls the hook, if the model has it, with the object.
+func (model *Model) runHook(hook Hook, object Object) (error) {
+	if hook == nil {
+		return nil
+	}
+	return hook(model, object)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator Validator
+		value     interface{}
+		want      string
+	}{
+		{"required nil", Required(), nil, "is required"},
+		{"required empty", Required(), "", "is required"},
+		{"required empty slice", Required(), []byte{}, "is required"},
+		{"required", Required(), 0, ""},
+		{"min length", MinLength(3), "ab", "must be at least 3 characters long"},
+		{"min length runes", MinLength(3), "été", ""},
+		{"min length nil", MinLength(3), nil, ""},
+		{"max length", MaxLength(2), []byte("abc"), "must be at most 2 characters long"},
+		{"max length", MaxLength(2), "ab", ""},
+		{"match", Match(`^[^@]+@[^@]+$`), "aki", "is not in the expected format"},
+		{"match", Match(`^[^@]+@[^@]+$`), "aki@salt", ""},
+		{"range", Range(0, 1.5), 2, "must be between 0 and 1.5"},
+		{"range decimal", Range(0, 1.5), "1.25", ""},
+		{"range uint", Range(0, 1.5), uint8(1), ""},
+		{"range not a number", Range(0, 1.5), "abc", "must be a number"},
+		{"range nil", Range(0, 1.5), nil, ""},
+	}
+	for _, test := range tests {
+		err := test.validator(test.value)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("%s : validator(%#v) = %q, want %q", test.name, test.value, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	users := &Model{Name: "users", Fields: Fields{
+		"Email": {Type: CharField, Validators: []Validator{Required(), Match(`^[^@]+@[^@]+$`)}},
+		"Name":  {Type: CharField, Validators: []Validator{MinLength(2), MaxLength(4)}},
+		"Age":   {Type: Integer, Validators: []Validator{Range(0, 150)}},
+	}}
+	object := NewObject()
+	object.Object["Name"] = "a"
+	object.Object["Age"] = 200
+	err := users.Validate(object)
+	want := ValidationErrors{"Email": {"is required"}, "Name": {"must be at least 2 characters long"}, "Age": {"must be between 0 and 150"}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Validate = %#v, want %#v", err, want)
+	}
+	if got := err.Error(); got != "Error : Validation failed : Age must be between 0 and 150; Email is required; Name must be at least 2 characters long" {
+		t.Errorf("Error = %q", got)
+	}
+	//An update only checks the fields it sets.
+	if err := users.validate(object, false); !reflect.DeepEqual(err, ValidationErrors{"Name": want["Name"], "Age": want["Age"]}) {
+		t.Errorf("validate of an update = %v", err)
+	}
+	object.Object["Email"], object.Object["Name"], object.Object["Age"] = "aki@salt", "aki", 30
+	if err := users.Validate(object); err != nil {
+		t.Errorf("Validate of a valid object = %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Versioned migrations are SQL files in a migrations directory :
+//
+//    migrations/
+//        20161018120000_auto.up.sql      -  the statements applying the migration
+//        20161018120000_auto.down.sql    -  the statements reverting it
+//        schema.json                     -  the columns of the tables after the latest migration
+//
+//MakeMigrations writes them from the changes between schema.json and the models, so they can be reviewed (and edited)
+//before ApplyMigrations runs them. The applied versions are recorded in the salt_migrations table.
+//
+//The first migration creates all the tables. For a database which already has them (made before the migrations were
+//used), it is recorded as applied with FakeMigrations instead of being run.
+
+//The table recording the applied migrations.
+const migrationsTable = "salt_migrations"
+
+//The file of the migrations directory containing the schema the migrations lead to.
+const schemaFile = "schema.json"
+
+//ErrNoChanges is returned by MakeMigrations when the models haven't changed since the last migration.
+var ErrNoChanges error = errors.New("Error : No changes in the models since the last migration")
+
+//MakeMigrations writes the migration files taking the schema of the latest migration in dir to the models and
+//returns the version of the new migration, which is named <timestamp>_<name>. If the models haven't changed, no
+//files are written and an error is returned.
+//
+//Tables of the schema not found in the models are dropped by the migration.
+func MakeMigrations(dir string, name string, models Models) (string, error) {
+	if (name == "") {
+		name = "auto"
+	}
+	previous, err := readSchema(dir)
+	if err != nil {
+		return "", err
+	}
+	current := make(map[string]tableSchema)
+	for index := range models {
+		current[models[index].Name] = models[index].schema()
+		for table, schema := range models[index].joinSchemas() {
+			current[table] = schema
+		}
+	}
+	up, err := diffSchemas(previous, current)
+	if err != nil {
+		return "", err
+	}
+	if len(up) == 0 {
+		return "", ErrNoChanges
+	}
+	down, err := diffSchemas(current, previous)
+	if err != nil {
+		return "", err
+	}
+	version := time.Now().UTC().Format("20060102150405") + "_" + name
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	err = writeMigration(filepath.Join(dir, version + ".up.sql"), version, "up", up)
+	if err != nil {
+		return "", err
+	}
+	err = writeMigration(filepath.Join(dir, version + ".down.sql"), version, "down", down)
+	if err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(current, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return version, ioutil.WriteFile(filepath.Join(dir, schemaFile), content, 0644)
+}
+
+//readSchema reads the schema of the latest migration. It is empty if there are no migrations yet.
+func readSchema(dir string) (map[string]tableSchema, error) {
+	schema := make(map[string]tableSchema)
+	content, err := ioutil.ReadFile(filepath.Join(dir, schemaFile))
+	if os.IsNotExist(err) {
+		return schema, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schema, json.Unmarshal(content, &schema)
+}
+
+//diffSchemas returns the statements changing the tables from one schema to the other. The new tables are created
+//after the tables they refer to and the old ones dropped before them.
+func diffSchemas(from map[string]tableSchema, to map[string]tableSchema) ([]string, error) {
+	var stmts []string
+	for _, table := range referenceOrder(to) {
+		schema, ok := from[table]
+		if !ok {
+			stmts = append(stmts, createStatements(dialect, table, to[table])...)
+			continue
+		}
+		altered, err := diffTable(dialect, table, schema, to[table], true)
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, altered...)
+	}
+	dropped := referenceOrder(from)
+	for i := len(dropped) - 1; i >= 0; i-- {
+		if _, ok := to[dropped[i]]; !ok {
+			stmts = append(stmts, "DROP TABLE " + quote(dropped[i]))
+		}
+	}
+	return stmts, nil
+}
+
+//referenceOrder returns the table names of the schema in order, each after the tables of the schema it refers to
+//(unless they refer to each other).
+func referenceOrder(schema map[string]tableSchema) ([]string) {
+	var ordered []string
+	placed := make(map[string]bool)
+	var place func(table string, visiting map[string]bool)
+	place = func(table string, visiting map[string]bool) {
+		if placed[table] || visiting[table] {
+			return
+		}
+		visiting[table] = true
+		for _, column := range schema[table].Columns {
+			if column.References != nil {
+				if _, ok := schema[column.References.Table]; ok {
+					place(column.References.Table, visiting)
+				}
+			}
+		}
+		placed[table] = true
+		ordered = append(ordered, table)
+	}
+	for _, table := range sortedTables(schema) {
+		place(table, make(map[string]bool))
+	}
+	return ordered
+}
+
+//sortedTables returns the table names of the schema in order, so the migrations are written the same way every time.
+func sortedTables(schema map[string]tableSchema) ([]string) {
+	var tables []string
+	for table := range schema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+//writeMigration writes the statements of one direction of a migration to the file, one per line.
+func writeMigration(filename string, version string, direction string, stmts []string) (error) {
+	content := "-- salt migration " + version + " (" + direction + ")\n"
+	for _, stmt := range stmts {
+		content += stmt + ";\n"
+	}
+	return ioutil.WriteFile(filename, []byte(content), 0644)
+}
+
+//readMigration reads the statements of a migration file (see splitStatements).
+func readMigration(filename string) ([]string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return splitStatements(string(content)), nil
+}
+
+//A dollar quote of PostgreSQL, $$ or $tag$. $1 is a placeholder.
+var dollarQuote = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z_0-9]*)?\$`)
+
+//A CREATE TRIGGER statement, whose body has statements of its own.
+var createTrigger = regexp.MustCompile(`(?is)^\s*CREATE\s+((TEMP|TEMPORARY)\s+)?TRIGGER\b`)
+
+//A keyword, or a name, of SQL.
+var sqlWord = regexp.MustCompile(`[A-Za-z_]+`)
+
+//splitStatements splits SQL into its statements. Statements end with a ";" outside of quotes ('...', "..." and `...`,
+//in which quotes are escaped by doubling them), of the dollar quoted bodies of PostgreSQL ($$...$$ or $tag$...$tag$)
+//and of comments ("--" to the end of the line and /* ... */, which are left out). The body of a CREATE TRIGGER goes on
+//until its END. Statements which need another delimiter, like the procedures of MySQL, are not supported.
+func splitStatements(sql string) ([]string) {
+	var stmts []string
+	//bare is the statement without its quoted parts, to find the BEGIN and END keywords.
+	var stmt, bare strings.Builder
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			for (i + 1 < len(sql)) && (sql[i + 1] != '\n') {
+				i++
+			}
+			stmt.WriteByte(' ')
+			bare.WriteByte(' ')
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i + 2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			stmt.WriteByte(' ')
+			bare.WriteByte(' ')
+		case (c == '\'') || (c == '"') || (c == '`'):
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] != c {
+					continue
+				}
+				if (j + 1 < len(sql)) && (sql[j + 1] == c) {
+					j++
+					continue
+				}
+				break
+			}
+			if j >= len(sql) {
+				j = len(sql) - 1
+			}
+			stmt.WriteString(sql[i : j + 1])
+			bare.WriteByte(' ')
+			i = j
+		case (c == '$') && dollarQuote.MatchString(sql[i:]):
+			tag := dollarQuote.FindString(sql[i:])
+			end := strings.Index(sql[i + len(tag):], tag)
+			j := len(sql)
+			if end >= 0 {
+				j = i + len(tag) + end + len(tag)
+			}
+			stmt.WriteString(sql[i:j])
+			bare.WriteByte(' ')
+			i = j - 1
+		case (c == ';') && !(createTrigger.MatchString(bare.String()) && inBody(bare.String())):
+			if trimmed := strings.TrimSpace(stmt.String()); trimmed != "" {
+				stmts = append(stmts, trimmed)
+			}
+			stmt.Reset()
+			bare.Reset()
+		default:
+			stmt.WriteByte(c)
+			bare.WriteByte(c)
+		}
+	}
+	if trimmed := strings.TrimSpace(stmt.String()); trimmed != "" {
+		stmts = append(stmts, trimmed)
+	}
+	return stmts
+}
+
+//inBody tells whether the end of the (unquoted) SQL of a CREATE TRIGGER is in its body, between its BEGIN and END.
+//CASE ... END, and the END IF, END WHILE, END LOOP and END REPEAT of MySQL, are nested in it.
+func inBody(sql string) (bool) {
+	words := sqlWord.FindAllString(strings.ToUpper(sql), -1)
+	depth, begun := 0, false
+	for k := 0; k < len(words); k++ {
+		switch words[k] {
+		case "BEGIN":
+			depth++
+			begun = true
+		case "CASE":
+			depth++
+		case "END":
+			if (k + 1 < len(words)) {
+				switch words[k + 1] {
+				case "IF", "WHILE", "LOOP", "REPEAT":
+					k++
+					continue
+				case "CASE":
+					k++
+				}
+			}
+			depth--
+		}
+	}
+	return begun && (depth > 0)
+}
+
+//Migrations returns the versions of the migrations in dir, oldest first.
+func Migrations(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, file := range files {
+		versions = append(versions, strings.TrimSuffix(filepath.Base(file), ".up.sql"))
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+//AppliedMigrations returns the versions of the migrations applied to the database, oldest first.
+func AppliedMigrations() ([]string, error) {
+	err := createMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := runQuery("SELECT " + quote("Version") + " FROM " + quote(migrationsTable) + " ORDER BY " + quote("Version"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []string
+	for rows.Next() {
+		var version string
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+//PendingMigrations returns the versions of the migrations in dir which are not applied yet, oldest first.
+func PendingMigrations(dir string) ([]string, error) {
+	versions, err := Migrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for _, version := range applied {
+		done[version] = true
+	}
+	var pending []string
+	for _, version := range versions {
+		if !done[version] {
+			pending = append(pending, version)
+		}
+	}
+	return pending, nil
+}
+
+//createMigrationsTable creates the salt_migrations table if it doesn't exist yet.
+func createMigrationsTable() (error) {
+	migrations := Model{Name : migrationsTable}
+	if migrations.IsMigrated() {
+		return nil
+	}
+	_, err := runExec(createTable(dialect, migrationsTable, []Column{
+		{Name : "Version", Type : dialect.ColumnType(Field{Type : CharField}), NotNull : true, PrimaryKey : true},
+		{Name : "AppliedAt", Type : dialect.ColumnType(Field{Type : CharField}), NotNull : true},
+	}))
+	return err
+}
+
+//ApplyMigrations applies the pending migrations in dir, oldest first, and returns their versions. Each migration is
+//run in a transaction with its record in salt_migrations (MySQL commits the schema changes right away though, so a
+//failed migration may be partly applied there).
+func ApplyMigrations(dir string) ([]string, error) {
+	pending, err := PendingMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	for _, version := range pending {
+		stmts, err := readMigration(filepath.Join(dir, version + ".up.sql"))
+		if err != nil {
+			return applied, err
+		}
+		LogMessage("Applying migration", version)
+		err = runMigration(stmts, "INSERT INTO " + quote(migrationsTable) + " (" + quote("Version") + "," + quote("AppliedAt") + ") VALUES (?,?)",
+			version, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return applied, errors.New("Error : Migration " + version + " failed : " + err.Error())
+		}
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
+
+//FakeMigrations records the pending migrations in dir as applied without running them and returns their versions. It
+//is the baseline of a database whose tables already match the migrations, eg. made by the models before the
+//migrations were used.
+func FakeMigrations(dir string) ([]string, error) {
+	pending, err := PendingMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range pending {
+		_, err = runExec("INSERT INTO " + quote(migrationsTable) + " (" + quote("Version") + "," + quote("AppliedAt") + ") VALUES (?,?)",
+			version, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+//RollbackMigrations reverts the last n applied migrations in dir, newest first, and returns their versions.
+func RollbackMigrations(dir string, n int) ([]string, error) {
+	applied, err := AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if n > len(applied) {
+		n = len(applied)
+	}
+	var reverted []string
+	for i := len(applied) - 1; i >= len(applied) - n; i-- {
+		version := applied[i]
+		stmts, err := readMigration(filepath.Join(dir, version + ".down.sql"))
+		if err != nil {
+			return reverted, err
+		}
+		LogMessage("Reverting migration", version)
+		err = runMigration(stmts, "DELETE FROM " + quote(migrationsTable) + " WHERE " + quote("Version") + "=?", version)
+		if err != nil {
+			return reverted, errors.New("Error : Reverting migration " + version + " failed : " + err.Error())
+		}
+		reverted = append(reverted, version)
+	}
+	return reverted, nil
+}
+
+//runMigration runs the statements of a migration and the statement recording it in one transaction, on a connection
+//whose foreign keys are checked at the end of the migration with SQLite (see schemaConnection).
+func runMigration(stmts []string, record string, args ...interface{}) (error) {
+	ctx := context.Background()
+	return schemaConnection(ctx, func(conn *sql.Conn) error {
+		return transaction(ctx, conn, func(tx *Tx) error {
+			for _, stmt := range stmts {
+				//The statements are run as they are written, without converting their placeholders.
+				start := time.Now()
+				result, err := tx.tx.ExecContext(tx.ctx, stmt)
+				logExec(tx.ctx, stmt, nil, start, result, err)
+				if err != nil {
+					return err
+				}
+			}
+			err := checkForeignKeys(tx.ctx, tx.tx.QueryContext)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(record, args...)
+			return err
+		})
+	})
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadMigration(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "20161018120000_auto.up.sql")
+	content := `-- salt migration 20161018120000_auto (up)
+CREATE TABLE "posts" ( "ID" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL);
+
+-- A statement written over several lines.
+ALTER TABLE "posts"
+    ADD COLUMN "Title" TEXT;
+UPDATE "posts" SET "Title" = 'untitled'`
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`CREATE TABLE "posts" ( "ID" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL)`,
+		"ALTER TABLE \"posts\"\n    ADD COLUMN \"Title\" TEXT",
+		`UPDATE "posts" SET "Title" = 'untitled'`,
+	}
+	got, err := readMigration(filename)
+	if (err != nil) || !reflect.DeepEqual(got, want) {
+		t.Errorf("readMigration =\n%q, %v\nwant\n%q", got, err, want)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"quotes", `INSERT INTO "notes" ("Body") VALUES ('one;
+two'), ('it''s;');
+UPDATE "a;b" SET ` + "`c;d`" + ` = 1`,
+			[]string{"INSERT INTO \"notes\" (\"Body\") VALUES ('one;\ntwo'), ('it''s;')", "UPDATE \"a;b\" SET `c;d` = 1"}},
+		{"comments", "-- first; second\nSELECT 1; /* a;\nb */ SELECT 2;\n-- the end;",
+			[]string{"SELECT 1", "SELECT 2"}},
+		{"dollar quotes", `CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+    NEW."UpdatedAt" := now();
+    RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT $1`,
+			[]string{"CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n    NEW.\"UpdatedAt\" := now();\n    RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql",
+				"SELECT $1"}},
+		{"trigger", `CREATE TRIGGER "notes_touch" AFTER UPDATE ON "notes" BEGIN
+    UPDATE "notes" SET "Kind" = CASE WHEN NEW."Body" = '' THEN 'empty' ELSE 'full' END;
+    DELETE FROM "drafts";
+END;
+CREATE TRIGGER "one" BEFORE INSERT ON "notes" FOR EACH ROW SET NEW."Body" = 'x';
+DROP TABLE "drafts";`,
+			[]string{"CREATE TRIGGER \"notes_touch\" AFTER UPDATE ON \"notes\" BEGIN\n    UPDATE \"notes\" SET \"Kind\" = CASE WHEN NEW.\"Body\" = '' THEN 'empty' ELSE 'full' END;\n    DELETE FROM \"drafts\";\nEND",
+				"CREATE TRIGGER \"one\" BEFORE INSERT ON \"notes\" FOR EACH ROW SET NEW.\"Body\" = 'x'",
+				`DROP TABLE "drafts"`}},
+	}
+	for _, test := range tests {
+		if got := splitStatements(test.sql); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s : splitStatements =\n%q\nwant\n%q", test.name, got, test.want)
+		}
+	}
+}
@@ -27,7 +27,14 @@ Usage : salt <command> [<command args>...]
 
 List of commands :
     create <webappname> [<webappdir>] - Create a new web app skeleton
-    run <webappname> - Run a created web app`
+    run <webappname> - Run a created web app
+    makemigrations <webappname> [<name>] - Write the migration files for the changes in the models
+    migrate <webappname> [--rollback N | --fake] - Apply the pending migrations, revert the last N ones or record
+        the pending ones as applied without running them (for a database which already has the tables)
+
+The migrations are plain SQL files (<version>.up.sql and <version>.down.sql in the migrations directory), run by
+migrate as they are written. Changes which need Go code, like filling a new column from the old ones, are not
+supported by the migrations and have to be run by the web app.`
 
 var app_json string =
 `{
@@ -40,10 +47,15 @@ var app_json string =
         "StaticURI" : "/static/",
         "StaticDirs" : ["static/"]
     },
+    "ShutdownTimeout" : "30s",
     "Database" : {
+        "Driver" : "mysql",
         "Username" : ${Username},
         "Password" : ${Password},
         "Database" : ${Database}
+    },
+    "Migrations" : {
+        "Dir" : "migrations"
     }
 }`
 
@@ -51,6 +63,7 @@ var appname_go string =
 `package main
 
 import (
+	"log"
 	"./{{appname}}"
 	"github.com/aki237/salt"
 )
@@ -59,7 +72,10 @@ func main(){
 	salt.Configure("app.json")
 	salt.Add404(NotFound)
 	salt.AddRootApp({{appname}}.App)
-	salt.Run()
+	err := salt.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 //Not found function
@@ -101,10 +117,10 @@ var Models models.Models = models.Models{
 	models.Model{
 		Name : "NEWMODEL",
 		Fields: models.Fields{
-			"ID":models.Field{models.Integer,true,true,true},
-			"MODEL_ENTITY_1":models.Field{models.CharField,false,false,false},
-			"MODEL_ENTITY_2":models.Field{models.CharField,false,false,false},
-			"MODEL_ENTITY_3":models.Field{models.CharField,false,false,false},
+			"ID":models.Field{Type : models.Integer, AutoIncrement : true, NotNull : true, Unique : true},
+			"MODEL_ENTITY_1":models.Field{Type : models.CharField},
+			"MODEL_ENTITY_2":models.Field{Type : models.CharField},
+			"MODEL_ENTITY_3":models.Field{Type : models.CharField},
 		},
 		PrimaryKey : "ID",
 	},
@@ -133,6 +149,28 @@ func main() {
 		}
 		run(args[2:])
 		return
+	case "makemigrations":
+		if (len(args) < 3) {
+			fmt.Println(RED + "Wrong Usage of makemigrations command" + NORMAL + "\n" + errormsg)
+			return
+		}
+		command(args[2], "makemigrations", args[3:])
+		return
+	case "migrate":
+		if (len(args) == 3) {
+			command(args[2], "migrate", nil)
+			return
+		}
+		if (len(args) == 5) && (args[3] == "--rollback") {
+			command(args[2], "rollback", args[4:])
+			return
+		}
+		if (len(args) == 4) && (args[3] == "--fake") {
+			command(args[2], "fake", nil)
+			return
+		}
+		fmt.Println(RED + "Wrong Usage of migrate command" + NORMAL + "\n" + errormsg)
+		return
 	default:
 		fmt.Println(RED + args[1] + NORMAL + " : Command not found")
 		return
@@ -236,6 +274,24 @@ func run(args []string){
 	cmd.Run()
 }
 
+//command runs the web-app with a salt command (see the SALT_COMMAND handling in salt.Run), which it does instead
+//of starting the server.
+func command(appname string, name string, args []string){
+	wd ,_ = os.Getwd()
+	if isexist , _ := exists(wd+"/"+appname+".go") ; !isexist {
+		fmt.Println(RED + appname +" : Unable to find the app files." + NORMAL)
+		return
+	}
+	cmd := exec.Command("go", "run",appname+".go")
+	cmd.Env = append(os.Environ(), "SALT_COMMAND="+name, "SALT_ARGS="+strings.Join(args, " "))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		fmt.Println(RED + name + " failed : " + err.Error() + NORMAL)
+	}
+}
+
 //
 func Replace(a string ,appname string) (string) {
 	return strings.Replace(a,"{{appname}}",appname,-1)
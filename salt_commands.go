@@ -0,0 +1,107 @@
+package salt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"github.com/aki237/salt/models"
+)
+
+//The salt tool runs the commands needing the models of a web-app (makemigrations, migrate) by running the web-app with
+//the command in these environment variables. Run then does the command instead of starting the server.
+const (
+	commandEnv     = "SALT_COMMAND"
+	commandArgsEnv = "SALT_ARGS"
+)
+
+//DefaultMigrationsDir is the directory of the versioned migrations if the configuration doesn't have a Migrations.Dir.
+const DefaultMigrationsDir = "migrations"
+
+//migrationsDir returns the directory of the versioned migrations of the server.
+func (s *Server) migrationsDir() string {
+	if s.config.Migrations.Dir == "" {
+		return DefaultMigrationsDir
+	}
+	return s.config.Migrations.Dir
+}
+
+//models returns the models of all the apps of the server.
+func (s *Server) models() models.Models {
+	var all models.Models
+	for _, app := range s.apps {
+		all = append(all, app.Models...)
+	}
+	return all
+}
+
+//command runs a salt command on the web-app :
+//
+//    makemigrations [<name>]  -  write the migration files for the changes in the models
+//    migrate                  -  apply the pending migrations
+//    fake                     -  record the pending migrations as applied without running them
+//    rollback [<n>]           -  revert the last n (default 1) migrations
+func (s *Server) command(command string, args []string) error {
+	dir := s.migrationsDir()
+	switch command {
+	case "makemigrations":
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+		version, err := models.MakeMigrations(dir, name, s.models())
+		if err == models.ErrNoChanges {
+			fmt.Println("No changes in the models")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created migration", version, "in", dir)
+		return nil
+	case "migrate":
+		applied, err := models.ApplyMigrations(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Applied", len(applied), "migrations")
+		return nil
+	case "fake":
+		faked, err := models.FakeMigrations(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Recorded", len(faked), "migrations as applied")
+		return nil
+	case "rollback":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return errors.New("The number of migrations to roll back should be a positive number : " + args[0])
+			}
+		}
+		reverted, err := models.RollbackMigrations(dir, n)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Reverted", len(reverted), "migrations")
+		return nil
+	}
+	return errors.New("Unknown salt command : " + command)
+}
+
+//checkMigrations warns about the versioned migrations not applied yet, if the server uses versioned migrations.
+func (s *Server) checkMigrations() {
+	if s.config.Migrations.Dir == "" {
+		return
+	}
+	pending, err := models.PendingMigrations(s.config.Migrations.Dir)
+	if err != nil {
+		fmt.Println("Unable to check the migrations :", err)
+		return
+	}
+	if len(pending) > 0 {
+		fmt.Println(len(pending), "migrations are not applied yet. Run : salt migrate <appname>")
+	}
+}
@@ -0,0 +1,47 @@
+package salt
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"github.com/aki237/salt/models"
+)
+
+//QueryCountHeader is the response header in which a server configured with Debug gives the number of statements the
+//models ran for the request, to spot views running a query per row (N+1 queries). Only the statements run with the
+//context of the request (r.Context(), see models.Model.WithContext) before the response headers are written are
+//counted.
+const QueryCountHeader = "X-Salt-Queries"
+
+//queryCountWriter sets the QueryCountHeader when the response headers are written.
+type queryCountWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+}
+
+func (w *queryCountWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set(QueryCountHeader, strconv.Itoa(models.QueryCount(w.ctx)))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *queryCountWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+//Unwrap returns the original ResponseWriter, for http.ResponseController.
+func (w *queryCountWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//countQueries makes the request count the statements the models run with its context and the response report them.
+func countQueries(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	r = r.WithContext(models.CountQueries(r.Context()))
+	return &queryCountWriter{ResponseWriter : w, ctx : r.Context()}, r
+}
@@ -0,0 +1,61 @@
+package salt
+
+import (
+	"github.com/aki237/salt/models"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	_ "modernc.org/sqlite"
+)
+
+func TestQueryCountHeader(t *testing.T) {
+	err := models.SetDatabaseConfig(models.Database{Driver: "sqlite", Database: filepath.Join(t.TempDir(), "salt.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.SetQueryLogger(nil)
+	defer models.SetQueryLogger(models.DefaultLogger)
+	users := &models.Model{Name: "users", PrimaryKey: "ID", Fields: models.Fields{"ID": {Type: models.Integer, AutoIncrement: true}}}
+	if err = users.Register(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	s.config.Debug = true
+	//Both requests are in flight while they run their queries, so the count has to be per request.
+	var started, done sync.WaitGroup
+	started.Add(2)
+	s.AddRoute("^/queries/<int:n>$", "queries", func(w ResponseBuffer, r *RequestBuffer) {
+		started.Done()
+		started.Wait()
+		for i := 0; i < r.URLParameters["n"].(int); i++ {
+			if _, err := users.WithContext(r.Context()).GetRecord("ID", i); err != nil {
+				t.Error(err)
+			}
+		}
+		w.Write([]byte("done"))
+	})
+	counts := make([]string, 2)
+	for i, n := range []int{1, 3} {
+		done.Add(1)
+		go func(i int, n int) {
+			defer done.Done()
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("GET", "/queries/"+strconv.Itoa(n), nil))
+			counts[i] = w.Header().Get(QueryCountHeader)
+		}(i, n)
+	}
+	done.Wait()
+	if (counts[0] != "1") || (counts[1] != "3") {
+		t.Errorf("%s = %q, want \"1\" and \"3\"", QueryCountHeader, counts)
+	}
+	//Without Debug the header is left out.
+	s.config.Debug = false
+	w := httptest.NewRecorder()
+	started.Add(1)
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/queries/1", nil))
+	if _, ok := w.Header()[QueryCountHeader]; ok {
+		t.Errorf("%s set without Debug", QueryCountHeader)
+	}
+}
@@ -4,32 +4,44 @@
 package salt
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"mime"
-	"net/http"
 	"os"
 	"strings"
+	"time"
 	"github.com/aki237/salt/models"
 )
 
-//The struct containing the information about all the url patterns
+//The struct containing the information about all the url patterns.
+//Methods restricts the route to the listed HTTP methods; leave it empty to answer every method.
+//Middlewares wrap the Handler of this route only.
 type URL struct {
 	Pattern string
 	Routename string
 	Handler Handler
+	Methods []string
+	Middlewares []Middleware
 }
 
 //Array of the URL Type
 type URLS []URL
 
-//App structure
+//App structure. Middlewares wrap the handlers of all the URLS of the app.
+//OnStart is called before the server starts listening; an error stops the server from starting.
+//OnShutdown is called when the server is shut down, after the requests in flight are done, to flush buffers, close
+//connections and the like. On SIGINT or SIGTERM, the context passed expires after the ShutdownTimeout of the
+//configuration.
 type App struct {
-	URLS    URLS
-	Models  models.Models
-	BaseURL string
+	URLS        URLS
+	Models      models.Models
+	BaseURL     string
+	Middlewares []Middleware
+	OnStart     func() error
+	OnShutdown  func(ctx context.Context) error
 }
 
 //Struct for storing the config read in the app.json file of the app
@@ -48,49 +60,34 @@ type Config struct{
 		PrivateKey  string
 		Certificate string
 	}
-}
-
-//The runtime variable : config - containing the configuration of an web-app read from the file passed
-//as a parameter to the Configure function
-var config Config
-
-//Boolean variable that states whether the web-app is configured with a Configure function call successfully
-var configured bool = false
-
-//Boolean variable that states whether a root app has been added web-app
-var rootapppresent bool = false
-
-
-//The function that actually listens to the listenVar (address:port or : port).
-//Here the server's root url is mapped to a default router function salt.router
-//This router inturn matches the urls with the registered patterns and Runs the Handler Function (in the Route struct).
-//After registering the routes, execute this functon to start the server.
-func Run() error {
-	http.HandleFunc("/", router)
-	return http.ListenAndServe(config.ListenVars.Address+":"+config.ListenVars.Port, nil)
-}
-
-func RunTLS() error {
-	http.HandleFunc("/", router)
-	return http.ListenAndServeTLS(config.ListenVars.Address+":"+config.ListenVars.Port,
-		config.TLS.Certificate, config.TLS.PrivateKey, nil)
-}
-
-//RunAt is similar to the Run function, but it doesn't take the listen variables form the configuraton imported.
-func RunAt(serveaddr string) error {
-	http.HandleFunc("/", router)
-	return http.ListenAndServe(serveaddr, nil)
+	//How long the requests in flight are waited for when the server is shut down, eg. "30s".
+	ShutdownTimeout string
+	//The changes to the tables of models which changed are printed when the apps are added, and only applied with
+	//Apply (and without DryRun). The columns removed from the models are never dropped then : that is left to the
+	//versioned migrations.
+	//With a Dir, the schema is only changed by the versioned migrations in that directory (see the salt makemigrations
+	//and salt migrate commands) and adding apps doesn't create or alter tables.
+	Migrations struct{
+		DryRun bool
+		Apply  bool
+		Dir    string
+	}
 }
 
 //This is the function that serves the static files present in the directories specified in the
 //configuration file at the static URL pattern (which again should also be specified in the config file).
 func StaticServe(w ResponseBuffer, r *RequestBuffer)  {
+	defaultServer.StaticServe(w, r)
+}
+
+//StaticServe serves the static files from the directories in the configuration of the server.
+func (s *Server) StaticServe(w ResponseBuffer, r *RequestBuffer)  {
 	filename := r.URLParameters["staticfile"].(string)
 	filename = strings.Replace(filename , "../","_",-1)
 	filename = strings.Replace(filename , "./","/",-1)
 	extention := strings.Split(filename, ".")[len(strings.Split(filename, ".")) - 1]
 
-	for _,entry := range config.Static.StaticDirs {
+	for _,entry := range s.config.Static.StaticDirs {
 		if exists(entry + "/" + filename) {
 			contenttype := mime.TypeByExtension("."+extention)
 			w.Header().Set("Content-Type", contenttype)
@@ -104,11 +101,18 @@ func StaticServe(w ResponseBuffer, r *RequestBuffer)  {
 		}
 	}
 
-	Func404(w,r)
+	s.notFound()(w,r)
 }
 
 //This function is used to configure a particular web-app
 func Configure(filename string)(error)  {
+	return defaultServer.Configure(filename)
+}
+
+//Configure configures the server from the configuration file. See the package level Configure.
+//The Database of the configuration is set for the models package, which has one connection pool for the process :
+//the last server configured sets it for all of them.
+func (s *Server) Configure(filename string)(error)  {
 
 	content, err := ioutil.ReadFile(filename)
 	if(err != nil){
@@ -116,53 +120,67 @@ func Configure(filename string)(error)  {
 	}
 
 
-	err = json.Unmarshal(content,&config)
-	if (len(config.Static.StaticURI) > 0){
-		log("Static File Directories detected : " , config.Static.StaticDirs)
-		if (string(config.Static.StaticURI[0]) != "/"){
-			config.Static.StaticURI = "^/" + config.Static.StaticURI
+	err = json.Unmarshal(content,&s.config)
+	if (len(s.config.Static.StaticURI) > 0){
+		s.log("Static File Directories detected : " , s.config.Static.StaticDirs)
+		if (string(s.config.Static.StaticURI[0]) != "/"){
+			s.config.Static.StaticURI = "^/" + s.config.Static.StaticURI
 		} else {
-			config.Static.StaticURI = "^" + config.Static.StaticURI
+			s.config.Static.StaticURI = "^" + s.config.Static.StaticURI
 		}
 
 
-		if (string(config.Static.StaticURI[len(config.Static.StaticURI)-1]) != "/"){
-			config.Static.StaticURI = config.Static.StaticURI + "/"
+		if (string(s.config.Static.StaticURI[len(s.config.Static.StaticURI)-1]) != "/"){
+			s.config.Static.StaticURI = s.config.Static.StaticURI + "/"
 		}
 		var staticdirs []string
-		for _, dirs := range config.Static.StaticDirs {
+		for _, dirs := range s.config.Static.StaticDirs {
 			stat,err := os.Stat(dirs)
 			if (os.IsNotExist(err)){
-				log("The specified static directory doesn't exist : " + dirs)
+				s.log("The specified static directory doesn't exist : " + dirs)
 			} else {
 				if (!stat.IsDir()){
-					log("The specified static entry is not a directory : " + dirs)
+					s.log("The specified static entry is not a directory : " + dirs)
 				} else {
 					staticdirs = append(staticdirs,dirs)
 				}
 			}
 		}
-		config.Static.StaticDirs = staticdirs
+		s.config.Static.StaticDirs = staticdirs
 		static := URL{
-			Pattern : config.Static.StaticURI + "<any:staticfile>",
+			Pattern : s.config.Static.StaticURI + "<any:staticfile>",
 			Routename : "Static",
-			Handler : StaticServe,
+			Handler : s.StaticServe,
+			Methods : []string{"GET"},
 		}
-		static.AddRoute()
+		s.addURL(static)
 
 	}
-	fmt.Println(config)
-	err = models.SetDatabaseConfig(config.Database)
+	if (s.config.ShutdownTimeout != ""){
+		s.shutdownTimeout, err = time.ParseDuration(s.config.ShutdownTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	//With Debug every statement of the models is logged, otherwise only the slow and failed ones.
+	models.SetDebug(s.config.Debug)
+	err = models.SetDatabaseConfig(s.config.Database)
 	if (err == nil){
-		configured = true
+		s.log("Able to open Connection to database")
+		s.configured = true
 	}
 	return err
 }
 
 //Add routes from an array of URL
 func (routes URLS)AddRoutes()  {
+	defaultServer.addURLS(routes)
+}
+
+//addURLS adds the routes from an array of URL to the server.
+func (s *Server) addURLS(routes URLS)  {
 	for _, val := range routes{
-		val.AddRoute()
+		s.addURL(val)
 	}
 }
 
@@ -170,9 +188,14 @@ func (routes URLS)AddRoutes()  {
 
 //Add a route from a URL variable
 func (routeconf URL)AddRoute()  {
-	err := AddRoute(routeconf.Pattern, routeconf.Routename, routeconf.Handler)
+	defaultServer.addURL(routeconf)
+}
+
+//addURL adds a route from a URL variable to the server.
+func (s *Server) addURL(routeconf URL)  {
+	err := s.addRoute(Route{nil, routeconf.Pattern, routeconf.Handler, routeconf.Routename, routeconf.Methods, routeconf.Middlewares})
 	if err != nil {
-		log(err)
+		s.log(err)
 	}
 }
 
@@ -181,25 +204,18 @@ func (routeconf URL)AddRoute()  {
 //This function is used to add a route app to the web-app. All salt web-app should have one (and only) root app.
 //An app here refers to the collection of urls, views and models.
 func AddRootApp(app App) (error) {
-	if !(rootapppresent) && (configured){
-		app.URLS.AddRoutes()
-		rootapppresent = true
-		fmt.Println("Registering App Models ...")
-		for _, val := range app.Models {
-			fmt.Println(val.Name)
-			if !val.IsMigrated() {
-				fmt.Println(val.Name," is not migrated yet.Migrating...")
-				err := val.Register()
-				if err != nil {
-					return err
-				}
-			} else {
-				fmt.Println("Already Migrated")
-			}
-		}
-		return nil
+	return defaultServer.AddRootApp(app)
+}
+
+//AddRootApp adds the root app to the server. See the package level AddRootApp.
+func (s *Server) AddRootApp(app App) (error) {
+	if !(s.rootapppresent) && (s.configured){
+		s.addURLS(app.urls())
+		s.apps = append(s.apps, app)
+		s.rootapppresent = true
+		return s.registerModels(app.Models)
 	}
-	if (!configured){
+	if (!s.configured){
 		return errors.New("The app is not configured")
 	}
 	return errors.New("A root app has already been registered. Try the URLS with AddApp.")
@@ -210,19 +226,24 @@ func AddRootApp(app App) (error) {
 
 //This function is used to add a new app to the web-app. A web-app can contian more than one auxillary apps.
 func AddApp(app App) (error)  {
-	if (!configured){
+	return defaultServer.AddApp(app)
+}
+
+//AddApp adds an auxillary app to the server. See the package level AddApp.
+func (s *Server) AddApp(app App) (error)  {
+	if (!s.configured){
 		return errors.New("The app is not configured")
 	}
-	if (!rootapppresent) {
+	if (!s.rootapppresent) {
 		return errors.New("Root app has not been registered.")
 	}
-	for _, val := range routes {
+	for _, val := range s.routes {
 
 		if (len(app.BaseURL) < len(val.Pattern)){
 
 			if (app.BaseURL == val.Pattern[:len(app.BaseURL)]){
 
-				log("The /api/ base Pattern has already been used in the root app. This new app may not work as expected.")
+				s.log("The /api/ base Pattern has already been used in the root app. This new app may not work as expected.")
 				break
 
 			}
@@ -233,8 +254,53 @@ func AddApp(app App) (error)  {
 		app.URLS[index].Pattern = app.BaseURL + app.URLS[index].Pattern
 	}
 
-	app.URLS.AddRoutes()
-	return app.Models.Register()
+	s.addURLS(app.urls())
+	s.apps = append(s.apps, app)
+	return s.registerModels(app.Models)
+}
+
+//registerModels creates the tables of the new models and prints the changes migrating the tables of the models already
+//in the database to their current definition, applying them if Migrations.Apply is set in the configuration. The
+//columns no longer in the models are kept.
+//With versioned migrations (or when running a salt command) it does nothing, the tables are left to the migrations.
+func (s *Server) registerModels(appmodels models.Models) (error) {
+	if (s.config.Migrations.Dir != "") || (os.Getenv(commandEnv) != ""){
+		return nil
+	}
+	s.log("Registering App Models ...")
+	for _, val := range appmodels {
+		s.log(val.Name)
+		if !val.IsMigrated() {
+			s.log(val.Name, "is not migrated yet. Migrating...")
+			err := val.Register()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		apply := s.config.Migrations.Apply && !s.config.Migrations.DryRun
+		stmts, err := val.Migrate(!apply, false)
+		if err != nil {
+			return err
+		}
+		switch {
+		case len(stmts) == 0:
+			s.log("Already Migrated")
+		case !apply:
+			models.LogMessage(val.Name, "has changed. The statements above were not applied (set Migrations.Apply to apply them).")
+		}
+	}
+	return nil
+}
+
+//urls returns the URLS of the app with the app's middlewares put before the middlewares of each url.
+func (app App) urls() URLS {
+	urls := make(URLS, len(app.URLS))
+	for index, url := range app.URLS {
+		url.Middlewares = append(append([]Middleware{}, app.Middlewares...), url.Middlewares...)
+		urls[index] = url
+	}
+	return urls
 }
 
 
@@ -332,7 +398,12 @@ func SampleHome(w ResponseBuffer, r *RequestBuffer)  {
 //This is the function is used to log the output of salt app internals. The Debug can be turned false in the web-app
 //configuration file.
 func log(a ...interface{})  {
-	if (config.Debug){
-		fmt.Println(a)
+	defaultServer.log(a...)
+}
+
+//log logs the output of salt app internals if the server is configured with Debug.
+func (s *Server) log(a ...interface{})  {
+	if (s.config.Debug){
+		fmt.Println(a...)
 	}
 }
@@ -0,0 +1,118 @@
+package salt
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//paramType describes a type of url pattern variable, the "int" in <int:postno>.
+type paramType struct {
+	pattern   string
+	converter func(string) (interface{}, error)
+	value     *regexp.Regexp
+}
+
+//placeholder matches the <type:name> url pattern variables.
+var placeholder = regexp.MustCompile("<([[:alpha:]]+):([[:alpha:]]+)>")
+
+//Global Private variable that contains the registered url pattern variable types.
+var paramTypes = make(map[string]*paramType)
+
+func init() {
+	builtin := []struct {
+		name      string
+		pattern   string
+		converter func(string) (interface{}, error)
+	}{
+		{"str", "[[:alpha:]]+", nil},
+		{"int", "[[:digit:]]+", func(s string) (interface{}, error) { return strconv.Atoi(s) }},
+		{"all", "[[:alnum:]]+", nil},
+		{"any", ".+", nil},
+		{"uuid", "[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12}", nil},
+		{"slug", "[a-z0-9]+(?:-[a-z0-9]+)*", nil},
+		{"float", "[-+]?[[:digit:]]+(?:\\.[[:digit:]]+)?", func(s string) (interface{}, error) { return strconv.ParseFloat(s, 64) }},
+		{"date", "[[:digit:]]{4}-[[:digit:]]{2}-[[:digit:]]{2}", func(s string) (interface{}, error) { return time.Parse("2006-01-02", s) }},
+		{"hex", "[[:xdigit:]]+", nil},
+	}
+	for _, t := range builtin {
+		err := RegisterParamType(t.name, t.pattern, t.converter)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+//RegisterParamType adds a new type of url pattern variable which can then be used in the patterns like the builtin ones.
+//
+// + name       -   The name of the type used in the patterns. Only alphabets are allowed.
+//
+// + pattern    -   The regexp matching a value of the type (without anchors).
+//
+// + converter  -   The function converting the matched string into the value stored in RequestBuffer.URLParameters.
+//                  If it is nil the string is stored as such.
+//
+//Example :
+//
+//    salt.RegisterParamType("year", "[[:digit:]]{4}", func(s string) (interface{}, error) { return strconv.Atoi(s) })
+//    salt.AddRoute("^/archive/<year:year>$", "archive", archive)
+//
+//If the converter returns an error for a matched string, the route is treated as not matching that url, so the request
+//falls through to the next matching route or the 404 handler.
+//
+//The builtin types are :
+//
+//    str   - [[:alpha:]]+                  string
+//    int   - [[:digit:]]+                  int
+//    all   - [[:alnum:]]+                  string
+//    any   - .+                            string
+//    uuid  - 8-4-4-4-12 hex digits         string
+//    slug  - lower case words and "-"      string
+//    float - decimal number                float64
+//    date  - YYYY-MM-DD                    time.Time
+//    hex   - [[:xdigit:]]+                 string
+//
+//When a pattern is compiled into the routing tree, a variable taking up a whole path segment only matches within that
+//segment (except a trailing "any" variable), so patterns of new types shouldn't match "/".
+//Types should be registered before the routes using them.
+func RegisterParamType(name string, pattern string, converter func(string) (interface{}, error)) error {
+	if !regexp.MustCompile("^[[:alpha:]]+$").MatchString(name) {
+		return errors.New("Invalid url variable type name : " + name)
+	}
+	if _, ok := paramTypes[name]; ok {
+		return errors.New("The url variable type " + name + " is already registered")
+	}
+	value, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return err
+	}
+	paramTypes[name] = &paramType{pattern, converter, value}
+	return nil
+}
+
+//RegisterEnumParamType adds a url pattern variable type matching only the values passed. For example, after
+//
+//    salt.RegisterEnumParamType("format", "json", "xml", "html")
+//
+//the pattern ^/report/<format:format>$ matches /report/json but not /report/pdf.
+func RegisterEnumParamType(name string, values ...string) error {
+	if len(values) == 0 {
+		return errors.New("No values given for the url variable type " + name)
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = regexp.QuoteMeta(value)
+	}
+	return RegisterParamType(name, strings.Join(quoted, "|"), nil)
+}
+
+//convert converts the raw value of a url variable of the given type. Variables without a type (named groups in raw
+//regexp patterns) are kept as strings.
+func (t *paramType) convert(value string) (interface{}, error) {
+	if t == nil || t.converter == nil {
+		return value, nil
+	}
+	return t.converter(value)
+}
@@ -39,9 +39,12 @@ import (
 	"fmt"
 	"github.com/aki237/salt/models"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 
@@ -54,12 +57,32 @@ type RegexpMap struct {
 
 type Handler func(ResponseBuffer , *RequestBuffer)
 
+//Middleware wraps a Handler into another one, which can run code before and after calling the wrapped handler or
+//not call it at all (for example an authentication middleware rejecting a request) :
+//
+//    func RequireLogin(next salt.Handler) salt.Handler {
+//        return func(w salt.ResponseBuffer, r *salt.RequestBuffer) {
+//            if _, err := r.Cookie("session"); err != nil {
+//                salt.Redirect(w, r, "/login", http.StatusFound)
+//                return
+//            }
+//            next(w, r)
+//        }
+//    }
+//
+//Middlewares are added globally with Use, for all the urls of an app with App.Middlewares and for a single route with
+//URL.Middlewares. A request passes through the global middlewares first, then the app's and then the route's before
+//reaching the handler. In each list, the first middleware is the outermost one.
+type Middleware func(Handler) Handler
+
 //Route is the type that contains routing information for different urls registered
 type Route struct {
 	RegexpPattern *RegexpMap
 	Pattern       string
 	Handler       Handler
 	Name          string
+	Methods       []string
+	Middlewares   []Middleware
 }
 
 
@@ -71,24 +94,24 @@ type ResponseBuffer http.ResponseWriter
 
 //This type is an extension of the http.Request package. In addition to the http.Request element, it also contains a map variable
 // of an interface mapped to names(string) of the URLPattern variables.
+//r.Context() is the context of the request, cancelled when the client goes away, which can be passed to the queries
+// of the models (eg. Users.WithContext(r.Context()).GetRecord("ID", id)) so they stop with the request.
 type RequestBuffer struct {
 	*http.Request
-	error         error
 	URLParameters map[string]interface{}
 }
 
 // Cookie type : directly derived from http.Cookie
 type Cookie http.Cookie
 
-//Global Private variable that contains all the registered routes
-var routes []Route
-
 //This is the variable of the type func(w ResponseBuffer , r *RequestBuffer),
 //This variable can be configured to run a custom 404 function, instead of the default one.
+//It is used by every Server without a NotFound handler of its own.
 var Func404 Handler = Default404
 
 //Default404 is the default 404 Not Found function.
 func Default404(w ResponseBuffer , r *RequestBuffer)  {
+	w.WriteHeader(http.StatusNotFound)
 	fmt.Fprint(w,"Page not found")
 }
 
@@ -97,30 +120,151 @@ func Add404(Handler  Handler)  {
 	Func404 = Handler
 }
 
-//This router function is the default router of root url of the server. Other URLs are routed from here.
-func router(w http.ResponseWriter, r *http.Request) {
+//Add404 sets the handler for the urls of the server not matching any route.
+func (s *Server) Add404(handler Handler) {
+	s.NotFound = handler
+}
+
+//notFound returns the 404 handler of the server.
+func (s *Server) notFound() Handler {
+	if s.NotFound != nil {
+		return s.NotFound
+	}
+	return Func404
+}
+
+//Use adds middlewares applied to every request of the web-app, including the ones answered by the 404 handler.
+//They run before the app and route middlewares, in the order they are added.
+func Use(mw ...Middleware) {
+	defaultServer.Use(mw...)
+}
+
+//Use adds middlewares applied to every request of the server. See the package level Use.
+func (s *Server) Use(mw ...Middleware) {
+	s.middlewares = append(s.middlewares, mw...)
+}
+
+//chain wraps the handler with the middlewares, the first one being the outermost.
+func chain(mws []Middleware, handler Handler) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+	return handler
+}
+
+//ServeHTTP is the router of the server. All the requests are routed from here.
+//Routes whose pattern matches but which are not registered for the request method are skipped. If no route
+//accepts the method, the request is answered with 405 Method Not Allowed (or the method list for OPTIONS).
+//With Debug, the response has the number of queries run for the request in the QueryCountHeader.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (s.config.Debug) {
+		w, r = countQueries(w, r)
+	}
+	handler, temp := s.resolve(r)
+	chain(s.middlewares, handler)(w, temp)
+}
+
+//resolve finds the handler for a request along with the RequestBuffer to pass to it.
+func (s *Server) resolve(r *http.Request) (Handler, *RequestBuffer) {
 	urlstr := r.URL.EscapedPath()
-	tmp := make(map[string]interface{}, 1)
-	var err error
-	if (len(routes) == 0){
-		SampleHome(w,&RequestBuffer{r,nil,nil})
+	var allowed []string
+	if (len(s.routes) == 0){
+		return SampleHome, &RequestBuffer{r,nil}
 	}
-	for _, route := range routes {
-		if route.RegexpPattern.MatchString(urlstr) {
-			temp := &RequestBuffer{r, err, tmp}
-			for _, mapname := range route.RegexpPattern.SubexpNames()[1:] {
-				switch route.RegexpPattern.typeMaps[mapname] {
-				case "str", "all","any":
-					temp.URLParameters[mapname] = route.RegexpPattern.ReplaceAllString(urlstr, "${"+mapname+"}")
-				case "int":
-					temp.URLParameters[mapname], temp.error = strconv.Atoi(route.RegexpPattern.ReplaceAllString(urlstr, "${"+mapname+"}"))
-				}
-			}
-			route.Handler(w, temp)
+	for _, match := range s.matchRoutes(urlstr) {
+		route := s.routes[match.index]
+		if !route.allows(r.Method) {
+			allowed = append(allowed, route.Methods...)
+			continue
+		}
+		temp, err := route.request(r, match.params)
+		if err != nil {
+			s.log("Skipping route ", route.Name, " : ", err)
+			continue
+		}
+		return chain(route.Middlewares, route.Handler), temp
+	}
+	if (len(allowed) > 0) {
+		return methodNotAllowed(allowHeader(allowed)), &RequestBuffer{r,nil}
+	}
+	return s.notFound(), &RequestBuffer{r,nil}
+}
+
+//methodNotAllowed returns the handler answering requests to urls whose routes don't accept the request method.
+//OPTIONS requests get the list of allowed methods.
+func methodNotAllowed(allow string) Handler {
+	return func(w ResponseBuffer, r *RequestBuffer) {
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		fmt.Fprint(w,"Method not allowed")
 	}
-	Func404(w, &RequestBuffer{r,nil,nil})
+}
+
+//request forms the RequestBuffer for the route, converting the url variables according to their types.
+func (route Route) request(r *http.Request, params map[string]string) (*RequestBuffer, error) {
+	temp := &RequestBuffer{r, make(map[string]interface{}, len(params))}
+	for mapname, value := range params {
+		converted, err := paramTypes[route.RegexpPattern.typeMaps[mapname]].convert(value)
+		if err != nil {
+			return nil, err
+		}
+		temp.URLParameters[mapname] = converted
+	}
+	return temp, nil
+}
+
+//allows reports whether the route can serve a request with the given method. A route without any
+//registered methods accepts every method, and HEAD requests are served by GET routes.
+func (route Route) allows(method string) bool {
+	if (len(route.Methods) == 0) {
+		return true
+	}
+	for _, m := range route.Methods {
+		if (m == method) || (method == http.MethodHead && m == http.MethodGet) {
+			return true
+		}
+	}
+	return false
+}
+
+//allowHeader forms the value of the Allow header from the methods of all the routes matching a path.
+func allowHeader(methods []string) string {
+	set := map[string]bool{http.MethodOptions: true}
+	for _, m := range methods {
+		set[m] = true
+		if m == http.MethodGet {
+			set[http.MethodHead] = true
+		}
+	}
+	list := make([]string, 0, len(set))
+	for m := range set {
+		list = append(list, m)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+//normalizeMethods upper-cases and de-duplicates a list of HTTP methods.
+func normalizeMethods(methods []string) []string {
+	var normalized []string
+	for _, m := range methods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		duplicate := false
+		for _, n := range normalized {
+			if n == m {
+				duplicate = true
+				break
+			}
+		}
+		if (m != "") && !duplicate {
+			normalized = append(normalized, m)
+		}
+	}
+	return normalized
 }
 
 
@@ -136,6 +280,8 @@ func router(w http.ResponseWriter, r *http.Request) {
 //           * str - Only alphabet class = [[:alpha:]]
 //           * int - Only the number Class = [[:digit:]]
 //           * all - Class formed by str + int = [[:alnum:]]
+//           * any - Any character = .
+//           * uuid, slug, float, date, hex and the types added with RegisterParamType
 //    - Variables are only constructed using Alphabets.
 /*    Example
  *  /<all:username>$                  translates to regexp pattern /(?P<username>[[:alnum:]]+)$
@@ -148,17 +294,48 @@ func router(w http.ResponseWriter, r *http.Request) {
 //    - This name is used to modify any route during the runtime using the ModifyRoute function in this package.
 // +  handler  -  The function which has to be called when the url pattern matches with the registered routes, with the request and the response buffers as parameters.
 //    - This is similar to the handler passed to http.HandleFunc but with the modified structs ResponseBuffer and RequestBuffer.
+//
+//A route added with AddRoute answers every HTTP method. Use AddRouteMethods to restrict it.
 func AddRoute(pattern string, routename string, handler Handler) (error) {
-	exp, err := Validate(pattern)
+	return defaultServer.AddRoute(pattern, routename, handler)
+}
+
+//AddRoute adds a new route to the server. See the package level AddRoute.
+func (s *Server) AddRoute(pattern string, routename string, handler Handler) (error) {
+	return s.AddRouteMethods(nil, pattern, routename, handler)
+}
+
+//AddRouteMethods is similar to AddRoute, but the route only answers the HTTP methods passed (eg. []string{"GET","POST"}).
+//Requests to a matching url with any other method are answered with 405 Method Not Allowed and an Allow header
+//listing the registered methods. HEAD requests are served by GET routes and OPTIONS requests are answered from the
+//registered methods unless a route registers OPTIONS itself. An empty methods list allows every method.
+//
+//Different routes can share a pattern with different methods, for example a "GET" listing and a "POST" form handler.
+func AddRouteMethods(methods []string, pattern string, routename string, handler Handler) (error) {
+	return defaultServer.AddRouteMethods(methods, pattern, routename, handler)
+}
+
+//AddRouteMethods adds a new route answering only the given HTTP methods to the server. See the package level
+//AddRouteMethods.
+func (s *Server) AddRouteMethods(methods []string, pattern string, routename string, handler Handler) (error) {
+	return s.addRoute(Route{nil, pattern, handler, routename, methods, nil})
+}
+
+//addRoute validates the pattern of the route and registers it.
+func (s *Server) addRoute(route Route) (error) {
+	exp, err := s.validate(route.Pattern)
 	if err != nil {
 		return err
 	}
-	for _, route := range routes {
-		if routename == route.Name {
+	for _, registered := range s.routes {
+		if route.Name == registered.Name {
 			return errors.New("The Name for this route is already used")
 		}
 	}
-	routes = append(routes, Route{exp, pattern, handler, routename})
+	route.RegexpPattern = exp
+	route.Methods = normalizeMethods(route.Methods)
+	s.routes = append(s.routes, route)
+	s.addToMatcher(len(s.routes) - 1)
 	return nil
 }
 
@@ -167,48 +344,47 @@ func AddRoute(pattern string, routename string, handler Handler) (error) {
 
 //Validate function is used to create a valid RegexpMap struct from the Pattern passed.
 func Validate(pattern string) (*RegexpMap, error) {
-	types := []string{"str", "int", "all","any"}
-	var regstr string
-	typeMaps := make(map[string]string, 1)
-
-	for _, kind := range types {
-		restr := regexp.MustCompile("<" + kind + ":[[:alpha:]]+>")
-		matches := restr.FindAllString(pattern, -1)
-
-		for _, val := range matches {
-			mapstr := strings.Replace(val, "<"+kind+":", "", -1)
-			mapstr = strings.Replace(mapstr, ">", "", -1)
-			for _, check := range matches {
-				if strings.Count(check, mapstr) > 1 {
-					return nil, errors.New("Variable used twice")
-				}
-			}
-			typeMaps[mapstr] = kind
-			switch kind {
-			case "str":
-				regstr = "alpha"
-			case "int":
-				regstr = "digit"
-			case "all":
-				regstr = "alnum"
-			case "any":
-				regstr = "."
-			}
-			if (regstr == "."){
-				pattern = strings.Replace(pattern, "<"+kind+":"+mapstr+">", "(?P<"+mapstr+">"+regstr+"+)", -1)
-			} else {
-				pattern = strings.Replace(pattern, "<"+kind+":"+mapstr+">", "(?P<"+mapstr+">[[:"+regstr+":]]+)", -1)
-			}
-		}
+	return defaultServer.validate(pattern)
+}
 
+//validate is Validate logging the pattern with the debug setting of the server.
+func (s *Server) validate(pattern string) (*RegexpMap, error) {
+	expr, typeMaps, err := translate(pattern)
+	if err != nil {
+		return nil, err
 	}
-
-	re := regexp.MustCompile(pattern)
-	log("pattern = ", re.String())
+	re := regexp.MustCompile(expr)
+	s.log("pattern = ", re.String())
 	newStruct := &RegexpMap{re, typeMaps}
 	return newStruct, nil
 }
 
+//translate converts the <type:name> variables in the pattern into named regexp groups and returns the resulting
+//regexp along with the type of every variable.
+func translate(pattern string) (string, map[string]string, error) {
+	typeMaps := make(map[string]string, 1)
+	var err error
+	pattern = placeholder.ReplaceAllStringFunc(pattern, func(val string) string {
+		submatches := placeholder.FindStringSubmatch(val)
+		kind, mapstr := submatches[1], submatches[2]
+		paramType, ok := paramTypes[kind]
+		if !ok {
+			err = errors.New("Unknown url variable type " + kind)
+			return val
+		}
+		if _, ok := typeMaps[mapstr]; ok {
+			err = errors.New("Variable used twice")
+			return val
+		}
+		typeMaps[mapstr] = kind
+		return "(?P<" + mapstr + ">" + paramType.pattern + ")"
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return pattern, typeMaps, nil
+}
+
 
 
 
@@ -223,18 +399,26 @@ func Validate(pattern string) (*RegexpMap, error) {
 // + pattern   -   New pattern string.
 //
 // + handler   -   The new handler for the pattern.
+//
+//The HTTP methods and the middlewares of the route are left unchanged.
 func ModifyRoute(oldname string, newname string, pattern string, handler Handler) error {
+	return defaultServer.ModifyRoute(oldname, newname, pattern, handler)
+}
+
+//ModifyRoute modifies a route registered in the server. See the package level ModifyRoute.
+func (s *Server) ModifyRoute(oldname string, newname string, pattern string, handler Handler) error {
 	var index int
-	for index, _ = range routes {
-		if routes[index].Name == oldname {
+	for index, _ = range s.routes {
+		if s.routes[index].Name == oldname {
 			break
 		}
 	}
-	exp, err := Validate(pattern)
+	exp, err := s.validate(pattern)
 	if err != nil {
 		return err
 	}
-	routes[index] = Route{exp, pattern, handler, newname}
+	s.routes[index] = Route{exp, pattern, handler, newname, s.routes[index].Methods, s.routes[index].Middlewares}
+	s.rebuildMatcher()
 	return nil
 }
 
@@ -243,15 +427,108 @@ func ModifyRoute(oldname string, newname string, pattern string, handler Handler
 
 //This function is used to register custom Route Variable to the routes variable.
 func (newroute Route) AddNewRouteObject() error {
-	for index, _ := range routes {
-		if routes[index].Name == newroute.Name {
+	return defaultServer.AddRouteObject(newroute)
+}
+
+//AddRouteObject registers a custom Route Variable to the routes of the server.
+func (s *Server) AddRouteObject(newroute Route) error {
+	for index, _ := range s.routes {
+		if s.routes[index].Name == newroute.Name {
 			return errors.New("There already exist a route with the same name.")
 		}
 	}
-	routes = append(routes, newroute)
+	newroute.Methods = normalizeMethods(newroute.Methods)
+	s.routes = append(s.routes, newroute)
+	s.addToMatcher(len(s.routes) - 1)
 	return nil
 }
 
+//URLFor builds the url path of the route registered with the name routename. Every <type:name> variable in the
+//route's pattern is replaced by params[name], which should be valid for the declared type :
+//
+//    salt.AddRoute("^/<all:username>/posts/<int:postno>$","post",getpost)
+//    salt.URLFor("post", map[string]interface{}{"username": "aki237", "postno": 898})  // "/aki237/posts/898"
+//
+//The ^ and $ anchors are dropped. An error is returned if the route doesn't exist, a variable is missing or doesn't
+//match its type, or if the pattern contains raw regexp constructs which can't be reversed into a single path.
+func URLFor(routename string, params map[string]interface{}) (string, error) {
+	return defaultServer.URLFor(routename, params)
+}
+
+//URLFor builds the url path of a route registered in the server. See the package level URLFor.
+func (s *Server) URLFor(routename string, params map[string]interface{}) (string, error) {
+	for _, route := range s.routes {
+		if route.Name == routename {
+			return reverse(route.Pattern, params)
+		}
+	}
+	return "", errors.New("No route named " + routename)
+}
+
+//reverse fills the variables in a url pattern with the params.
+func reverse(pattern string, params map[string]interface{}) (string, error) {
+	pattern = strings.TrimPrefix(pattern, "^")
+	pattern = strings.TrimSuffix(pattern, "$")
+	var path string
+	last := 0
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(pattern, -1) {
+		literal, err := unquoteLiteral(pattern[last:loc[0]])
+		if err != nil {
+			return "", err
+		}
+		kind, name := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+		value, ok := params[name]
+		if !ok {
+			return "", errors.New("Missing value for url variable " + name)
+		}
+		paramType, ok := paramTypes[kind]
+		if !ok {
+			return "", errors.New("Unknown url variable type " + kind)
+		}
+		str := fmt.Sprint(value)
+		if t, ok := value.(time.Time); ok && kind == "date" {
+			str = t.Format("2006-01-02")
+		}
+		if !paramType.value.MatchString(str) {
+			return "", errors.New("The value " + str + " is not valid for the url variable <" + kind + ":" + name + ">")
+		}
+		if kind == "any" {
+			segments := strings.Split(str, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			str = strings.Join(segments, "/")
+		}
+		path += literal + str
+		last = loc[1]
+	}
+	literal, err := unquoteLiteral(pattern[last:])
+	if err != nil {
+		return "", err
+	}
+	return path + literal, nil
+}
+
+//unquoteLiteral removes regexp escapes from the literal part of a pattern. Patterns containing regexp operators
+//can't be reversed.
+func unquoteLiteral(literal string) (string, error) {
+	var unquoted []byte
+	for i := 0; i < len(literal); i++ {
+		switch literal[i] {
+		case '\\':
+			i++
+			if i < len(literal) {
+				unquoted = append(unquoted, literal[i])
+			}
+		case '+', '*', '?', '(', ')', '|', '[', ']', '{', '}', '^', '$':
+			return "", errors.New("The pattern part " + literal + " contains regexp operators and can't be reversed")
+		default:
+			unquoted = append(unquoted, literal[i])
+		}
+	}
+	return string(unquoted), nil
+}
+
 //GetFormValue returns the form value for the given name "key" and error.
 func (r *RequestBuffer) GetFormValue(key string) (string,error) {
 	err := r.ParseForm()
@@ -262,6 +539,27 @@ func (r *RequestBuffer) GetFormValue(key string) (string,error) {
 	return r.FormValue(key),nil
 }
 
+//Pagination reads the page and per_page query parameters of the request, for models.Paginate. The page is 1 when it
+//is missing or not a positive number. The number of rows per page is perPage when it is missing or not a positive
+//number, and at most maxPerPage.
+//
+//    page, perPage := r.Pagination(20, 100)
+//    posts, err := models.Paginate(Posts.Query().OrderBy("-ID"), page, perPage)
+func (r *RequestBuffer) Pagination(perPage int, maxPerPage int) (int, int) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if (err != nil) || (page < 1) {
+		page = 1
+	}
+	if requested, err := strconv.Atoi(query.Get("per_page")); (err == nil) && (requested > 0) {
+		perPage = requested
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return page, perPage
+}
+
 // ExportFormToModelObject returns a models.Object and error. This function extracts all values from a
 // http form and put it in a models.Object struct.
 func (r *RequestBuffer) ExportFormToModelObject () (models.Object,error) {
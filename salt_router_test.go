@@ -0,0 +1,317 @@
+package salt
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//writes returns a handler writing the body.
+func writes(body string) Handler {
+	return func(w ResponseBuffer, r *RequestBuffer) {
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestRouterMethods(t *testing.T) {
+	s := NewServer()
+	s.AddRouteMethods([]string{"GET"}, "^/posts$", "listposts", writes("list"))
+	s.AddRouteMethods([]string{"post"}, "^/posts$", "createpost", writes("create"))
+	s.AddRouteMethods([]string{"OPTIONS", "PUT"}, "^/cors$", "cors", writes("cors"))
+	s.AddRoute("^/any$", "any", writes("any"))
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{"GET", "/posts", 200, "list", ""},
+		{"POST", "/posts", 200, "create", ""},
+		//HEAD requests are served by the GET route.
+		{"HEAD", "/posts", 200, "list", ""},
+		{"DELETE", "/posts", 405, "Method not allowed", "GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/posts", 204, "", "GET, HEAD, OPTIONS, POST"},
+		//A route registering OPTIONS answers it itself.
+		{"OPTIONS", "/cors", 200, "cors", ""},
+		{"GET", "/cors", 405, "Method not allowed", "OPTIONS, PUT"},
+		{"DELETE", "/any", 200, "any", ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if (w.Code != test.status) || (w.Body.String() != test.body) || (w.Header().Get("Allow") != test.allow) {
+			t.Errorf("%s %s = %d %q Allow %q, want %d %q Allow %q", test.method, test.path,
+				w.Code, w.Body.String(), w.Header().Get("Allow"), test.status, test.body, test.allow)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		pattern string
+		params  map[string]interface{}
+		want    string
+	}{
+		{"^/<all:username>/posts/<int:postno>$", map[string]interface{}{"username": "aki237", "postno": 898}, "/aki237/posts/898"},
+		{"^/static/<any:staticfile>", map[string]interface{}{"staticfile": "css/a b.css"}, "/static/css/a%20b.css"},
+		{"^/post-<int:postno>\\.html$", map[string]interface{}{"postno": 7}, "/post-7.html"},
+		{"^/about$", nil, "/about"},
+	}
+	for _, test := range tests {
+		got, err := reverse(test.pattern, test.params)
+		if (err != nil) || (got != test.want) {
+			t.Errorf("reverse(%q) = %q, %v, want %q", test.pattern, got, err, test.want)
+		}
+	}
+	failing := []struct {
+		pattern string
+		params  map[string]interface{}
+	}{
+		{"^/<int:postno>$", nil},
+		{"^/<int:postno>$", map[string]interface{}{"postno": "new"}},
+		{"^/<str:name>$", map[string]interface{}{"name": "a/b"}},
+		{"/archive/(?P<year>[0-9]{4})$", map[string]interface{}{"year": 2016}},
+	}
+	for _, test := range failing {
+		if got, err := reverse(test.pattern, test.params); err == nil {
+			t.Errorf("reverse(%q, %v) = %q, want an error", test.pattern, test.params, got)
+		}
+	}
+}
+
+func TestParamTypes(t *testing.T) {
+	s := NewServer()
+	RegisterEnumParamType("format", "json", "xml")
+	show := func(w ResponseBuffer, r *RequestBuffer) {
+		for _, name := range []string{"day", "price", "format"} {
+			fmt.Fprintf(w, "%T ", r.URLParameters[name])
+		}
+	}
+	s.AddRoute("^/report/<date:day>/<float:price>/<format:format>$", "report", show)
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/report/2016-10-18/12.5/json", "time.Time float64 string "},
+		{"/report/2016-10-18/12.5/pdf", "Page not found"},
+		//The converter failing is like the route not matching.
+		{"/report/2016-19-45/12.5/xml", "Page not found"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Body.String() != test.body {
+			t.Errorf("GET %s = %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+	if err := RegisterParamType("format", "[a-z]+", nil); err == nil {
+		t.Error("RegisterParamType of a registered type : no error")
+	}
+}
+
+//records returns a middleware adding its name to the X-Trace header before calling the next handler.
+func records(name string) Middleware {
+	return func(next Handler) Handler {
+		return func(w ResponseBuffer, r *RequestBuffer) {
+			w.Header().Add("X-Trace", name)
+			next(w, r)
+		}
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	s := NewServer()
+	s.Use(records("server1"), records("server2"))
+	//deny answers the requests with a X-Deny header itself, without calling the handler.
+	deny := func(next Handler) Handler {
+		return func(w ResponseBuffer, r *RequestBuffer) {
+			if r.Header.Get("X-Deny") != "" {
+				w.WriteHeader(403)
+				fmt.Fprint(w, "denied")
+				return
+			}
+			next(w, r)
+		}
+	}
+	app := App{Middlewares: []Middleware{records("app"), deny}, URLS: URLS{
+		{Pattern: "^/posts$", Routename: "posts", Handler: writes("posts"), Methods: []string{"GET"}, Middlewares: []Middleware{records("route")}},
+		{Pattern: "^/about$", Routename: "about", Handler: writes("about")},
+	}}
+	s.addURLS(app.urls())
+	tests := []struct {
+		method string
+		path   string
+		deny   bool
+		status int
+		body   string
+		trace  string
+	}{
+		{"GET", "/posts", false, 200, "posts", "server1 server2 app route"},
+		{"GET", "/about", false, 200, "about", "server1 server2 app"},
+		{"GET", "/posts", true, 403, "denied", "server1 server2 app"},
+		//The server middlewares also wrap the 404 and 405 answers.
+		{"POST", "/posts", false, 405, "Method not allowed", "server1 server2"},
+		{"GET", "/nope", false, 404, "Page not found", "server1 server2"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.path, nil)
+		if test.deny {
+			r.Header.Set("X-Deny", "1")
+		}
+		s.ServeHTTP(w, r)
+		trace := strings.Join(w.Header()["X-Trace"], " ")
+		if (w.Code != test.status) || (w.Body.String() != test.body) || (trace != test.trace) {
+			t.Errorf("%s %s (deny %v) = %d %q through %q, want %d %q through %q", test.method, test.path, test.deny,
+				w.Code, w.Body.String(), trace, test.status, test.body, test.trace)
+		}
+	}
+}
+
+func TestServers(t *testing.T) {
+	blog, shop := NewServer(), NewServer()
+	//The route names and the 404 handlers are per server.
+	blog.AddRoute("^/$", "home", writes("blog"))
+	shop.AddRoute("^/$", "home", writes("shop"))
+	shop.AddRoute("^/items/<int:id>$", "item", writes("item"))
+	shop.Add404(writes("no such item"))
+	tests := []struct {
+		server *Server
+		path   string
+		body   string
+	}{
+		{blog, "/", "blog"},
+		{shop, "/", "shop"},
+		{shop, "/items/3", "item"},
+		{blog, "/items/3", "Page not found"},
+		{shop, "/items/new", "no such item"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.server.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Body.String() != test.body {
+			t.Errorf("GET %s = %q, want %q", test.path, w.Body.String(), test.body)
+		}
+	}
+	if path, err := shop.URLFor("item", map[string]interface{}{"id": 3}); (err != nil) || (path != "/items/3") {
+		t.Errorf("URLFor of the shop = %q, %v", path, err)
+	}
+	if _, err := blog.URLFor("item", map[string]interface{}{"id": 3}); err == nil {
+		t.Error("URLFor of a route of another server : no error")
+	}
+}
+
+//linearMatch is the matcher the router used before the segment tree : every route's regexp is run against the path
+//and each variable is extracted with its own ReplaceAllString. It is kept here to compare the two.
+func (s *Server) linearMatch(path string) []routeMatch {
+	var matches []routeMatch
+	for index, route := range s.routes {
+		if route.RegexpPattern.MatchString(path) {
+			params := make(map[string]string)
+			for _, mapname := range route.RegexpPattern.SubexpNames()[1:] {
+				params[mapname] = route.RegexpPattern.ReplaceAllString(path, "${"+mapname+"}")
+			}
+			matches = append(matches, routeMatch{index, params})
+		}
+	}
+	return matches
+}
+
+//withRoutes returns a new server with the patterns registered.
+func withRoutes(tb testing.TB, patterns []string) *Server {
+	s := NewServer()
+	for i, pattern := range patterns {
+		if err := s.AddRoute(pattern, fmt.Sprint("route", i), nil); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return s
+}
+
+//manyRoutes returns n resources with the usual list, detail and edit routes plus a static and a regexp route.
+func manyRoutes(n int) []string {
+	var patterns []string
+	for i := 0; i < n; i++ {
+		patterns = append(patterns,
+			fmt.Sprintf("^/resource%d/$", i),
+			fmt.Sprintf("^/resource%d/<int:id>$", i),
+			fmt.Sprintf("^/resource%d/<int:id>/edit$", i),
+			fmt.Sprintf("^/resource%d/<all:owner>/<str:slug>$", i),
+		)
+	}
+	return append(patterns, "^/static/<any:staticfile>", "/archive/(?P<year>[0-9]{4})$")
+}
+
+func TestMatchRoutes(t *testing.T) {
+	s := withRoutes(t, []string{
+		"^/$",
+		"^/<all:username>$",
+		"^/admin$",
+		"^/<all:username>/posts/<int:postno>$",
+		"^/<all:username>/posts/new$",
+		"/feed/<all:username>$",
+		"^/post-<int:postno>\\.html$",
+		"^/static/<any:staticfile>",
+		"^/files/a.b$",
+		"^/about",
+	})
+	paths := []string{
+		"/", "/aki237", "/admin", "/aki237/posts/898", "/aki237/posts/new", "/aki237/posts/",
+		"/feed/aki237", "/post-12.html", "/static/css/main.css", "/static/", "/files/a.b", "/files/aXb",
+		"/aboutus", "/nothing/here", "",
+	}
+	for _, path := range paths {
+		got, linear := s.matchRoutes(path), s.linearMatch(path)
+		if !reflect.DeepEqual(got, linear) {
+			t.Errorf("%q : matchRoutes = %v, linear matcher = %v", path, got, linear)
+		}
+	}
+	if len(s.regexpRoutes) != 4 {
+		t.Errorf("%d routes matched with regexps, expected 4", len(s.regexpRoutes))
+	}
+}
+
+func benchmarkMatcher(b *testing.B, matcher func(*Server, string) []routeMatch, path string) {
+	s := withRoutes(b, manyRoutes(100))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(matcher(s, path)) == 0 {
+			b.Fatal("no route matched ", path)
+		}
+	}
+}
+
+func BenchmarkLinearFirst(b *testing.B) { benchmarkMatcher(b, (*Server).linearMatch, "/resource0/") }
+func BenchmarkTreeFirst(b *testing.B)   { benchmarkMatcher(b, (*Server).matchRoutes, "/resource0/") }
+
+func BenchmarkLinearLast(b *testing.B) { benchmarkMatcher(b, (*Server).linearMatch, "/resource99/aki237/salt") }
+func BenchmarkTreeLast(b *testing.B)   { benchmarkMatcher(b, (*Server).matchRoutes, "/resource99/aki237/salt") }
+
+func BenchmarkLinearStatic(b *testing.B) { benchmarkMatcher(b, (*Server).linearMatch, "/static/css/main.css") }
+func BenchmarkTreeStatic(b *testing.B)   { benchmarkMatcher(b, (*Server).matchRoutes, "/static/css/main.css") }
+
+func BenchmarkLinearRegexp(b *testing.B) { benchmarkMatcher(b, (*Server).linearMatch, "/archive/2016") }
+func BenchmarkTreeRegexp(b *testing.B)   { benchmarkMatcher(b, (*Server).matchRoutes, "/archive/2016") }
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		query         string
+		page, perPage int
+	}{
+		{"", 1, 20},
+		{"?page=3&per_page=50", 3, 50},
+		{"?page=0&per_page=-5", 1, 20},
+		{"?page=two&per_page=x", 1, 20},
+		{"?page=2&per_page=1000", 2, 100},
+	}
+	for _, test := range tests {
+		r := &RequestBuffer{httptest.NewRequest("GET", "/posts"+test.query, nil), nil}
+		if page, perPage := r.Pagination(20, 100); (page != test.page) || (perPage != test.perPage) {
+			t.Errorf("Pagination of %q = %d, %d, want %d, %d", test.query, page, perPage, test.page, test.perPage)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package salt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+//Server is a salt web-app : its configuration, routes, apps, middlewares and 404 handler. It implements http.Handler,
+//so more than one web-app can run in a process, be mounted in another http.ServeMux or be tested with
+//net/http/httptest :
+//
+//    server := salt.NewServer()
+//    err := server.Configure("app.json")
+//    ...
+//    server.AddRootApp(sampleapp.App)
+//    server.Run()
+//
+//The package level functions (Configure, AddRootApp, AddRoute, Run ...) act on a default Server, which is what the
+//web-apps created by the salt tool use.
+//
+//A Server doesn't own a database : the models package has a single connection pool and dialect, set by the last
+//Server configured, so the web-apps of a process share one database.
+type Server struct {
+	//NotFound is the handler for the urls not matching any route. If it is nil, the package variable Func404 is used.
+	NotFound Handler
+
+	config          Config
+	configured      bool
+	rootapppresent  bool
+	apps            []App
+	routes          []Route
+	middlewares     []Middleware
+	tree            *routeNode
+	regexpRoutes    []int
+	shutdownTimeout time.Duration
+	mutex           sync.Mutex
+	httpServer      *http.Server
+}
+
+//DefaultShutdownTimeout is how long a server waits for the requests in flight to finish when it is shut down, if the
+//configuration doesn't have a ShutdownTimeout.
+const DefaultShutdownTimeout = 30 * time.Second
+
+//The Server used by the package level functions.
+var defaultServer = NewServer()
+
+//NewServer returns a new Server without any configuration or routes.
+func NewServer() *Server {
+	return &Server{tree: &routeNode{}, shutdownTimeout: DefaultShutdownTimeout}
+}
+
+//The function that actually listens to the listenVar (address:port or : port).
+//Here the server's root url is mapped to the salt router.
+//This router inturn matches the urls with the registered patterns and Runs the Handler Function (in the Route struct).
+//After registering the routes, execute this functon to start the server.
+//
+//The server is shut down gracefully on SIGINT or SIGTERM : it stops accepting connections, waits for the requests in
+//flight to finish (up to the ShutdownTimeout in the configuration) and then calls the OnShutdown hooks of the apps.
+//The OnStart hooks of the apps are called before the server starts listening. Run returns nil after a graceful
+//shutdown.
+func Run() error {
+	return defaultServer.Run()
+}
+
+//Run listens at the address and port in the configuration of the server and serves the requests.
+func (s *Server) Run() error {
+	return s.serve(s.config.ListenVars.Address+":"+s.config.ListenVars.Port, (*http.Server).ListenAndServe)
+}
+
+//RunTLS is similar to the Run function, but serves HTTPS with the certificate and private key files in the
+//configuration imported.
+func RunTLS() error {
+	return defaultServer.RunTLS()
+}
+
+//RunTLS listens at the address and port in the configuration of the server and serves HTTPS requests.
+func (s *Server) RunTLS() error {
+	return s.serve(s.config.ListenVars.Address+":"+s.config.ListenVars.Port, func(server *http.Server) error {
+		return server.ListenAndServeTLS(s.config.TLS.Certificate, s.config.TLS.PrivateKey)
+	})
+}
+
+//RunAt is similar to the Run function, but it doesn't take the listen variables form the configuraton imported.
+func RunAt(serveaddr string) error {
+	return defaultServer.RunAt(serveaddr)
+}
+
+//RunAt listens at the given address and serves the requests.
+func (s *Server) RunAt(serveaddr string) error {
+	return s.serve(serveaddr, (*http.Server).ListenAndServe)
+}
+
+//Shutdown gracefully shuts down the running default server. See Server.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return defaultServer.Shutdown(ctx)
+}
+
+//Shutdown gracefully shuts down the server started with Run, RunTLS or RunAt, as it is done when the process gets
+//SIGINT or SIGTERM : it stops accepting connections, waits for the requests in flight until the context is done and
+//then calls the OnShutdown hooks of the apps.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	server := s.httpServer
+	s.httpServer = nil
+	s.mutex.Unlock()
+	if server == nil {
+		return errors.New("The server is not running")
+	}
+	err := server.Shutdown(ctx)
+	for _, app := range s.apps {
+		if app.OnShutdown == nil {
+			continue
+		}
+		if hookerr := app.OnShutdown(ctx); hookerr != nil {
+			s.log("OnShutdown : ", hookerr)
+			if err == nil {
+				err = hookerr
+			}
+		}
+	}
+	return err
+}
+
+//serve runs the OnStart hooks, starts the server at the address with listen and shuts it down on SIGINT or SIGTERM.
+func (s *Server) serve(addr string, listen func(*http.Server) error) error {
+	if command := os.Getenv(commandEnv); command != "" {
+		return s.command(command, strings.Fields(os.Getenv(commandArgsEnv)))
+	}
+	s.checkMigrations()
+	for _, app := range s.apps {
+		if app.OnStart == nil {
+			continue
+		}
+		if err := app.OnStart(); err != nil {
+			return err
+		}
+	}
+	server := &http.Server{Addr: addr, Handler: s}
+	s.mutex.Lock()
+	s.httpServer = server
+	s.mutex.Unlock()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
+	signalled := make(chan struct{})
+	shutdown := make(chan error, 1)
+	go func() {
+		select {
+		case sig := <-signals:
+			s.log("Received ", sig, ", shutting down")
+			close(signalled)
+			ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+			defer cancel()
+			shutdown <- s.Shutdown(ctx)
+		case <-done:
+		}
+	}()
+
+	err := listen(server)
+	if err != http.ErrServerClosed {
+		s.mutex.Lock()
+		s.httpServer = nil
+		s.mutex.Unlock()
+		return err
+	}
+	select {
+	case <-signalled:
+		//Wait for the requests in flight and the OnShutdown hooks.
+		return <-shutdown
+	default:
+		//Shut down with Shutdown, which returns the result to its own caller.
+		return nil
+	}
+}
@@ -0,0 +1,127 @@
+package salt
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+//configuredServer returns a new server as if it was configured, with the app as its root app.
+func configuredServer(t *testing.T, app App) *Server {
+	s := NewServer()
+	s.configured = true
+	if err := s.AddRootApp(app); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+//freeAddress returns a local address nothing listens at.
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestOnStartError(t *testing.T) {
+	failed := errors.New("failed")
+	shutdown := false
+	s := configuredServer(t, App{
+		OnStart:    func() error { return failed },
+		OnShutdown: func(ctx context.Context) error { shutdown = true; return nil },
+	})
+	if err := s.RunAt(freeAddress(t)); err != failed {
+		t.Errorf("RunAt returned %v, want the OnStart error", err)
+	}
+	if shutdown {
+		t.Error("OnShutdown called for a server which didn't start")
+	}
+	if err := s.Shutdown(context.Background()); err == nil {
+		t.Error("Shutdown of a server which didn't start : no error")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var mutex sync.Mutex
+	var events []string
+	event := func(name string) {
+		mutex.Lock()
+		events = append(events, name)
+		mutex.Unlock()
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func(w ResponseBuffer, r *RequestBuffer) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+		event("request")
+	}
+	s := configuredServer(t, App{
+		URLS:       URLS{{Pattern: "^/ping$", Routename: "ping", Handler: writes("pong")}, {Pattern: "^/slow$", Routename: "slow", Handler: slow}},
+		OnStart:    func() error { event("start"); return nil },
+		OnShutdown: func(ctx context.Context) error { event("shutdown"); return nil },
+	})
+	addr := freeAddress(t)
+	running := make(chan error, 1)
+	go func() {
+		running <- s.RunAt(addr)
+	}()
+	for i := 0; ; i++ {
+		resp, err := http.Get("http://" + addr + "/ping")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+		body <- string(content)
+	}()
+	<-started
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(ctx)
+	}()
+	//The server waits for the request in flight.
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v before the request in flight was done", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("the request in flight got %q", got)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown returned %v", err)
+	}
+	if err := <-running; err != nil {
+		t.Errorf("RunAt returned %v after Shutdown", err)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if (len(events) != 3) || (events[0] != "start") || (events[1] != "request") || (events[2] != "shutdown") {
+		t.Errorf("events = %v, want [start request shutdown]", events)
+	}
+}
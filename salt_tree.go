@@ -0,0 +1,235 @@
+package salt
+
+import (
+	"sort"
+	"strings"
+)
+
+//The routes are matched in two ways. Patterns that are plain paths built from literal segments and whole segment
+//<type:name> variables are compiled into a segment tree, so a request only walks the branches its own path segments
+//lead to. All the other patterns (raw regexps, variables inside a segment, patterns without the ^ anchor ...) are
+//matched with their regexp, as before.
+//
+//Tree patterns should start with ^/ and end with $, or with an <any:name> variable which takes up the rest of the path.
+//For example :
+//
+//    ^/<all:username>/posts/<int:postno>$       tree
+//    ^/static/<any:staticfile>                  tree
+//    /<all:username>$                           regexp (not anchored to the beginning)
+//    ^/post-<int:postno>\.html$                 regexp (variable inside a segment)
+//
+//Either way, the first registered route that matches a path wins.
+
+//routeNode is a node in the route segment tree. Each node corresponds to a path segment.
+type routeNode struct {
+	static map[string]*routeNode
+	params []*paramNode
+	rest   []restRoute
+	routes []int
+}
+
+//paramNode is a child of a routeNode reached by a whole segment url pattern variable.
+type paramNode struct {
+	kind string
+	name string
+	node *routeNode
+}
+
+//restRoute is a route whose pattern ends with an <any:name> variable, which matches the rest of the path.
+type restRoute struct {
+	name  string
+	index int
+}
+
+//routeMatch is a route matching a path along with the raw (unconverted) values of its url pattern variables.
+type routeMatch struct {
+	index  int
+	params map[string]string
+}
+
+//addToMatcher makes the route at index in the routes of the server available to matchRoutes.
+func (s *Server) addToMatcher(index int) {
+	route := s.routes[index]
+	segments, ok := treeSegments(route)
+	if !ok {
+		s.regexpRoutes = append(s.regexpRoutes, index)
+		return
+	}
+	node := s.tree
+	for i, segment := range segments {
+		loc := placeholder.FindStringSubmatchIndex(segment)
+		if loc == nil {
+			if node.static == nil {
+				node.static = make(map[string]*routeNode)
+			}
+			if node.static[segment] == nil {
+				node.static[segment] = &routeNode{}
+			}
+			node = node.static[segment]
+			continue
+		}
+		kind, name := segment[loc[2]:loc[3]], segment[loc[4]:loc[5]]
+		if kind == "any" && i == len(segments)-1 {
+			node.rest = append(node.rest, restRoute{name, index})
+			return
+		}
+		var child *paramNode
+		for _, p := range node.params {
+			if p.kind == kind && p.name == name {
+				child = p
+				break
+			}
+		}
+		if child == nil {
+			child = &paramNode{kind, name, &routeNode{}}
+			node.params = append(node.params, child)
+		}
+		node = child.node
+	}
+	node.routes = append(node.routes, index)
+}
+
+//rebuildMatcher compiles all the registered routes again. It is used when a route is modified.
+func (s *Server) rebuildMatcher() {
+	s.tree = &routeNode{}
+	s.regexpRoutes = nil
+	for index := range s.routes {
+		s.addToMatcher(index)
+	}
+}
+
+//treeSegments splits the pattern of a route into its path segments (with the escapes removed from the literal
+//segments) if it can be matched with the segment tree.
+func treeSegments(route Route) ([]string, bool) {
+	pattern := route.Pattern
+	if !strings.HasPrefix(pattern, "^/") {
+		return nil, false
+	}
+	//The regexp should be exactly what Validate makes of the pattern, custom Route objects may carry another one.
+	expr, _, err := translate(pattern)
+	if err != nil || route.RegexpPattern == nil || route.RegexpPattern.String() != expr {
+		return nil, false
+	}
+	pattern = pattern[2:]
+	anchored := strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, "\\$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		loc := placeholder.FindStringIndex(segment)
+		if loc != nil {
+			if loc[0] != 0 || loc[1] != len(segment) {
+				return nil, false
+			}
+			if strings.HasPrefix(segment, "<any:") && i != len(segments)-1 {
+				return nil, false
+			}
+			continue
+		}
+		literal, ok := literalSegment(segment)
+		if !ok {
+			return nil, false
+		}
+		segments[i] = literal
+	}
+	last := segments[len(segments)-1]
+	if !anchored && !strings.HasPrefix(last, "<any:") {
+		return nil, false
+	}
+	return segments, true
+}
+
+//literalSegment removes the escapes from a pattern segment if it only matches itself, ie. it has no regexp operators,
+//no unescaped "." and no escaped character classes like \d.
+func literalSegment(segment string) (string, bool) {
+	for i := 0; i < len(segment); i++ {
+		switch c := segment[i]; {
+		case c == '\\':
+			i++
+			if i == len(segment) || isAlnum(segment[i]) {
+				return "", false
+			}
+		case c == '.':
+			return "", false
+		}
+	}
+	literal, err := unquoteLiteral(segment)
+	return literal, err == nil
+}
+
+//isAlnum reports whether the byte is an ASCII letter or digit.
+func isAlnum(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+//matchRoutes returns all the routes of the server matching the path, in the order they were registered.
+func (s *Server) matchRoutes(path string) []routeMatch {
+	var matches []routeMatch
+	if strings.HasPrefix(path, "/") {
+		matches = s.tree.match(path, 1, nil, matches)
+	}
+	for _, index := range s.regexpRoutes {
+		exp := s.routes[index].RegexpPattern
+		submatches := exp.FindStringSubmatch(path)
+		if submatches == nil {
+			continue
+		}
+		params := make(map[string]string, len(submatches)-1)
+		for i, name := range exp.SubexpNames() {
+			if name != "" {
+				params[name] = submatches[i]
+			}
+		}
+		matches = append(matches, routeMatch{index, params})
+	}
+	if len(matches) > 1 {
+		sort.Slice(matches, func(i, j int) bool { return matches[i].index < matches[j].index })
+	}
+	return matches
+}
+
+//match walks the tree for the part of the path starting at offset, which is the beginning of a segment.
+//The values of the variables crossed so far are in the params, which are copied for each match found.
+func (node *routeNode) match(path string, offset int, params []string, matches []routeMatch) []routeMatch {
+	for _, rest := range node.rest {
+		if offset < len(path) {
+			matches = append(matches, routeMatch{rest.index, paramMap(append(params, rest.name, path[offset:]))})
+		}
+	}
+	end := strings.IndexByte(path[offset:], '/')
+	last := end < 0
+	if last {
+		end = len(path)
+	} else {
+		end += offset
+	}
+	segment := path[offset:end]
+	next := func(child *routeNode, params []string) []routeMatch {
+		if last {
+			for _, index := range child.routes {
+				matches = append(matches, routeMatch{index, paramMap(params)})
+			}
+			return matches
+		}
+		return child.match(path, end+1, params, matches)
+	}
+	if child, ok := node.static[segment]; ok {
+		matches = next(child, params)
+	}
+	for _, p := range node.params {
+		if paramTypes[p.kind].value.MatchString(segment) {
+			matches = next(p.node, append(params[:len(params):len(params)], p.name, segment))
+		}
+	}
+	return matches
+}
+
+//paramMap converts a list of url variable name and value pairs into a map.
+func paramMap(pairs []string) map[string]string {
+	params := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		params[pairs[i]] = pairs[i+1]
+	}
+	return params
+}
@@ -1,14 +1,104 @@
 package templates
 
 import (
+	"errors"
 	"github.com/aki237/salt"
+	"github.com/aki237/salt/models"
 	"html/template"
+	"net/url"
+	"path/filepath"
+	"strconv"
 )
 
+//FuncMap contains the functions available in the templates pushed with PushTemplate. More functions can be added to
+//it before the templates are pushed.
+//
+//    urlfor "routename" "variable" value ...  -  the url path of a route, built with salt.URLFor from the
+//                                                 variable name and value pairs.
+//    pager page "link"                         -  the links to the pages of a models.Page (see Pager).
+//
+//Example :
+//
+//    <a href="{{urlfor "post" "username" .User "postno" .ID}}">{{.Title}}</a>
+//    {{pager .Posts "/posts"}}
+var FuncMap = template.FuncMap{
+	"urlfor": URLFor,
+	"pager":  Pager,
+}
+
+//The number of pages linked on each side of the current one by Pager.
+const pagerWindow = 2
+
 func PushTemplate(filename string, w *salt.ResponseBuffer ,fillers interface{}) (error) {
-	t,err := template.ParseFiles(filename)
+	t,err := template.New(filepath.Base(filename)).Funcs(FuncMap).ParseFiles(filename)
 	if err != nil {
 		return err
 	}
 	return t.Execute(*w, fillers)
 }
+
+//URLFor is the template version of salt.URLFor. The url variables are passed as name and value pairs instead of a map.
+func URLFor(routename string, pairs ...interface{}) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", errors.New("urlfor : the url variables should be passed as name and value pairs")
+	}
+	params := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		name, ok := pairs[i].(string)
+		if !ok {
+			return "", errors.New("urlfor : the url variable names should be strings")
+		}
+		params[name] = pairs[i+1]
+	}
+	return salt.URLFor(routename, params)
+}
+
+//Pager renders the links to the pages around the current one of a models.Page, with the first and the last pages and
+//the previous and next ones :
+//
+//    <nav class="pager"><a class="prev" href="/posts?page=2&amp;per_page=20">&laquo; Previous</a> ... </nav>
+//
+//The links are the link with the page and per_page query parameters set (see salt.RequestBuffer.Pagination), its
+//other parameters are kept. Nothing is rendered if there is only one page.
+func Pager(page models.Page, link string) (template.HTML, error) {
+	if page.Pages <= 1 {
+		return "", nil
+	}
+	base, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	href := func(n int) string {
+		u := *base
+		query := u.Query()
+		query.Set("page", strconv.Itoa(n))
+		query.Set("per_page", strconv.Itoa(page.PerPage))
+		u.RawQuery = query.Encode()
+		return template.HTMLEscapeString(u.String())
+	}
+	html := `<nav class="pager">`
+	if page.HasPrev {
+		html += `<a class="prev" href="` + href(page.Prev()) + `">&laquo; Previous</a>`
+	}
+	gap := false
+	for n := 1; n <= page.Pages; n++ {
+		near := (n >= page.Page - pagerWindow) && (n <= page.Page + pagerWindow)
+		if (n != 1) && (n != page.Pages) && !near {
+			if !gap {
+				html += `<span class="gap">&hellip;</span>`
+			}
+			gap = true
+			continue
+		}
+		gap = false
+		if n == page.Page {
+			html += `<span class="current">` + strconv.Itoa(n) + `</span>`
+		} else {
+			html += `<a href="` + href(n) + `">` + strconv.Itoa(n) + `</a>`
+		}
+	}
+	if page.HasNext {
+		html += `<a class="next" href="` + href(page.Next()) + `">Next &raquo;</a>`
+	}
+	return template.HTML(html + `</nav>`), nil
+}
@@ -0,0 +1,37 @@
+package templates
+
+import (
+	"github.com/aki237/salt/models"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	tests := []struct {
+		page models.Page
+		link string
+		want string
+	}{
+		{models.Page{Page: 1, PerPage: 10, Pages: 1}, "/posts", ""},
+		{models.Page{Page: 1, PerPage: 10, Pages: 2, HasNext: true}, "/posts",
+			`<nav class="pager"><span class="current">1</span><a href="/posts?page=2&amp;per_page=10">2</a>` +
+				`<a class="next" href="/posts?page=2&amp;per_page=10">Next &raquo;</a></nav>`},
+		//The pages far from the current one are left out, but for the first and the last ones.
+		{models.Page{Page: 5, PerPage: 10, Pages: 9, HasNext: true, HasPrev: true}, "/posts?tag=go&page=1",
+			`<nav class="pager"><a class="prev" href="/posts?page=4&amp;per_page=10&amp;tag=go">&laquo; Previous</a>` +
+				`<a href="/posts?page=1&amp;per_page=10&amp;tag=go">1</a><span class="gap">&hellip;</span>` +
+				`<a href="/posts?page=3&amp;per_page=10&amp;tag=go">3</a><a href="/posts?page=4&amp;per_page=10&amp;tag=go">4</a>` +
+				`<span class="current">5</span>` +
+				`<a href="/posts?page=6&amp;per_page=10&amp;tag=go">6</a><a href="/posts?page=7&amp;per_page=10&amp;tag=go">7</a>` +
+				`<span class="gap">&hellip;</span><a href="/posts?page=9&amp;per_page=10&amp;tag=go">9</a>` +
+				`<a class="next" href="/posts?page=6&amp;per_page=10&amp;tag=go">Next &raquo;</a></nav>`},
+	}
+	for _, test := range tests {
+		got, err := Pager(test.page, test.link)
+		if (err != nil) || (string(got) != test.want) {
+			t.Errorf("Pager(%+v, %q) =\n%s, %v\nwant\n%s", test.page, test.link, got, err, test.want)
+		}
+	}
+	if _, err := Pager(models.Page{Page: 1, Pages: 2}, "%zz"); err == nil {
+		t.Error("Pager of an invalid link : no error")
+	}
+}